@@ -0,0 +1,70 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"chirpy-project/internal/entities"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// storeChirpTags records the hashtags in a chirp's current body. Tags already
+// recorded for the chirp keep the time they were first added.
+func storeChirpTags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	tags := entities.ExtractHashtags(chirp.Body)
+	if len(tags) == 0 {
+		return nil
+	}
+	return q.AddChirpTags(ctx, database.AddChirpTagsParams{
+		ChirpID: chirp.ID,
+		Tags:    tags,
+	})
+}
+
+// storeChirpMentions resolves the @handles in a chirp's current body and
+// records the ones that belong to a user. It returns the distinct users that
+// were mentioned; handles nobody has claimed are left as plain text.
+func storeChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) ([]uuid.UUID, error) {
+	mentions := entities.ExtractMentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil, nil
+	}
+
+	handles := []string{}
+	for _, mention := range mentions {
+		handles = append(handles, entities.NormalizeHandle(mention.Handle))
+	}
+	users, err := q.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return nil, err
+	}
+	userByHandle := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		userByHandle[entities.NormalizeHandle(user.Handle.String)] = user.ID
+	}
+
+	params := database.AddChirpMentionsParams{ChirpID: chirp.ID}
+	mentioned := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for _, mention := range mentions {
+		userID, ok := userByHandle[entities.NormalizeHandle(mention.Handle)]
+		if !ok {
+			continue
+		}
+		params.UserIds = append(params.UserIds, userID)
+		params.Offsets = append(params.Offsets, int32(mention.Offset))
+		params.Lengths = append(params.Lengths, int32(mention.Length))
+		if !seen[userID] {
+			seen[userID] = true
+			mentioned = append(mentioned, userID)
+		}
+	}
+	if len(mentioned) == 0 {
+		return nil, nil
+	}
+
+	if err := q.AddChirpMentions(ctx, params); err != nil {
+		return nil, err
+	}
+	return mentioned, nil
+}
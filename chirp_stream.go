@@ -0,0 +1,317 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpEventsChannel        = "chirp_events"
+	notificationEventsChannel = "notification_events"
+	// chirpEventRetention is how far back a client can resume with Last-Event-ID
+	chirpEventRetention = 24 * time.Hour
+	// subscriberBuffer is how many events a slow client may fall behind by
+	// before it is dropped and has to reconnect.
+	subscriberBuffer = 64
+)
+
+// Kinds of chirp events, as written by the log_chirp_event trigger.
+const (
+	chirpEventCreated = "created"
+	chirpEventDeleted = "deleted"
+)
+
+// chirpEvent is one row of the chirp_events log, as announced over
+// LISTEN/NOTIFY.
+type chirpEvent struct {
+	ID      int64         `json:"id"`
+	Kind    string        `json:"kind"`
+	ChirpID uuid.UUID     `json:"chirp_id"`
+	UserID  uuid.UUID     `json:"user_id"`
+	RootID  uuid.NullUUID `json:"root_id"`
+}
+
+func chirpEventFromDB(ev database.ChirpEvent) chirpEvent {
+	return chirpEvent{
+		ID:      ev.ID,
+		Kind:    ev.Kind,
+		ChirpID: ev.ChirpID,
+		UserID:  ev.UserID,
+		RootID:  ev.RootID,
+	}
+}
+
+// notificationEvent announces a new notification for UserID. Only the ID is
+// sent so the payload is loaded fresh from the database.
+type notificationEvent struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// subscription receives every event published after it was created. Done is
+// closed if the subscriber fell too far behind and was dropped, or the hub
+// shut down.
+type subscription[T any] struct {
+	Events chan T
+	Done   chan struct{}
+	once   sync.Once
+}
+
+func newSubscription[T any]() *subscription[T] {
+	return &subscription[T]{
+		Events: make(chan T, subscriberBuffer),
+		Done:   make(chan struct{}),
+	}
+}
+
+func (s *subscription[T]) close() {
+	s.once.Do(func() { close(s.Done) })
+}
+
+// send hands ev to the subscriber without blocking and reports whether it
+// had room for it.
+func (s *subscription[T]) send(ev T) bool {
+	select {
+	case s.Events <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+// chirpHub fans chirp events and new notifications out to the streams
+// connected to this instance. Events arrive through Postgres LISTEN/NOTIFY,
+// so a chirp created through any instance reaches clients on all of them.
+type chirpHub struct {
+	dbQueries *database.Queries
+
+	mu               sync.Mutex
+	closed           bool
+	subs             map[*subscription[chirpEvent]]struct{}
+	notificationSubs map[uuid.UUID]map[*subscription[notificationEvent]]struct{}
+	lastID           int64
+}
+
+func newChirpHub(dbQueries *database.Queries) *chirpHub {
+	return &chirpHub{
+		dbQueries:        dbQueries,
+		subs:             map[*subscription[chirpEvent]]struct{}{},
+		notificationSubs: map[uuid.UUID]map[*subscription[notificationEvent]]struct{}{},
+	}
+}
+
+func (h *chirpHub) subscribe() *subscription[chirpEvent] {
+	sub := newSubscription[chirpEvent]()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.close()
+		return sub
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *chirpHub) unsubscribe(sub *subscription[chirpEvent]) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+	sub.close()
+}
+
+// subscribeNotifications receives the notifications created for userID.
+func (h *chirpHub) subscribeNotifications(userID uuid.UUID) *subscription[notificationEvent] {
+	sub := newSubscription[notificationEvent]()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.close()
+		return sub
+	}
+	if h.notificationSubs[userID] == nil {
+		h.notificationSubs[userID] = map[*subscription[notificationEvent]]struct{}{}
+	}
+	h.notificationSubs[userID][sub] = struct{}{}
+	return sub
+}
+
+func (h *chirpHub) unsubscribeNotifications(userID uuid.UUID, sub *subscription[notificationEvent]) {
+	h.mu.Lock()
+	delete(h.notificationSubs[userID], sub)
+	if len(h.notificationSubs[userID]) == 0 {
+		delete(h.notificationSubs, userID)
+	}
+	h.mu.Unlock()
+	sub.close()
+}
+
+// publish hands an event to every subscriber without blocking. Subscribers
+// whose buffer is full are dropped rather than holding up everyone else.
+func (h *chirpHub) publish(ev chirpEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Transactions can commit out of ID order, so lastID is only a high-water
+	// mark for catching up and never a reason to drop an event.
+	h.lastID = max(h.lastID, ev.ID)
+	for sub := range h.subs {
+		if !sub.send(ev) {
+			delete(h.subs, sub)
+			sub.close()
+		}
+	}
+}
+
+func (h *chirpHub) publishNotification(ev notificationEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.notificationSubs[ev.UserID] {
+		if !sub.send(ev) {
+			delete(h.notificationSubs[ev.UserID], sub)
+			sub.close()
+		}
+	}
+}
+
+// run listens for chirp events until ctx is cancelled, then disconnects
+// every subscriber.
+func (h *chirpHub) run(ctx context.Context, dbURL string) {
+	defer h.closeAll()
+
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			fmt.Printf("Chirp event listener error: %v\n", err)
+		}
+	})
+	defer listener.Close()
+	// Listen blocks until the database is reachable; closing the listener is
+	// the only way to get it to give up
+	context.AfterFunc(ctx, func() { listener.Close() })
+	if !listenWithRetry(ctx, listener, chirpEventsChannel, notificationEventsChannel) {
+		return
+	}
+
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established and
+			// chirp events may have been missed while it was down. Missed
+			// notifications are not replayed; they are still in the inbox.
+			if n == nil {
+				h.catchUp(ctx)
+				continue
+			}
+			if n.Channel == notificationEventsChannel {
+				ev := notificationEvent{}
+				if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+					fmt.Printf("Error decoding notification event: %v\n", err)
+					continue
+				}
+				h.publishNotification(ev)
+				continue
+			}
+			ev := chirpEvent{}
+			if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+				fmt.Printf("Error decoding chirp event: %v\n", err)
+				continue
+			}
+			h.publish(ev)
+		case <-time.After(90 * time.Second):
+			// Make sure the connection is still alive when things are quiet
+			go listener.Ping()
+		case <-prune.C:
+			err := h.dbQueries.DeleteChirpEventsBefore(ctx, time.Now().UTC().Add(-chirpEventRetention))
+			if err != nil {
+				fmt.Printf("Error pruning chirp events: %v\n", err)
+			}
+		}
+	}
+}
+
+// listenWithRetry listens on channels, retrying with backoff for as long as
+// it takes, since streams stay open but silent until it succeeds. It only
+// gives up when ctx is done.
+func listenWithRetry(ctx context.Context, listener *pq.Listener, channels ...string) bool {
+	backoff := time.Second
+	for {
+		var err error
+		for _, channel := range channels {
+			// Channels from an earlier attempt are still being listened on
+			if err = listener.Listen(channel); err != nil && !errors.Is(err, pq.ErrChannelAlreadyOpen) {
+				if ctx.Err() != nil {
+					return false
+				}
+				fmt.Printf("Error listening on %s, retrying in %s: %v\n", channel, backoff, err)
+				break
+			}
+			err = nil
+		}
+		if err == nil {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// catchUp republishes events logged since the last one seen, in order.
+func (h *chirpHub) catchUp(ctx context.Context) {
+	h.mu.Lock()
+	lastID := h.lastID
+	h.mu.Unlock()
+	if lastID == 0 {
+		return
+	}
+
+	events, err := h.dbQueries.ListChirpEventsAfter(ctx, database.ListChirpEventsAfterParams{
+		ID:    lastID,
+		Limit: 1000,
+	})
+	if err != nil {
+		fmt.Printf("Error catching up on chirp events: %v\n", err)
+		return
+	}
+	for _, ev := range events {
+		h.publish(chirpEventFromDB(ev))
+	}
+}
+
+func (h *chirpHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		sub.close()
+	}
+	for userID, subs := range h.notificationSubs {
+		for sub := range subs {
+			sub.close()
+		}
+		delete(h.notificationSubs, userID)
+	}
+}
+
+// isClosed reports whether the hub has shut down.
+func (h *chirpHub) isClosed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// buildChirpResponses maps database chirps onto API responses and fills in
+// the per-chirp engagement data. viewerID is the authenticated caller, if
+// any, and controls the viewer-specific fields such as liked_by_me.
+func (cfg *apiConfig) buildChirpResponses(ctx context.Context, viewerID uuid.NullUUID, chirps []database.Chirp) ([]chirpResponse, error) {
+	return cfg.buildChirpResponsesDepth(ctx, viewerID, chirps, true)
+}
+
+// buildChirpResponsesDepth does the work for buildChirpResponses. Referenced
+// chirps are only embedded one level deep, so expandRefs is false when
+// building the embedded chirps themselves.
+func (cfg *apiConfig) buildChirpResponsesDepth(ctx context.Context, viewerID uuid.NullUUID, chirps []database.Chirp, expandRefs bool) ([]chirpResponse, error) {
+	resps := make([]chirpResponse, 0, len(chirps))
+	if len(chirps) == 0 {
+		return resps, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	likeCounts, err := cfg.dbQueries.CountChirpLikes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	countByID := make(map[uuid.UUID]int64, len(likeCounts))
+	for _, row := range likeCounts {
+		countByID[row.ChirpID] = row.LikeCount
+	}
+
+	var likedByViewer map[uuid.UUID]bool
+	if viewerID.Valid {
+		liked, err := cfg.dbQueries.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+			UserID:   viewerID.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		likedByViewer = make(map[uuid.UUID]bool, len(liked))
+		for _, id := range liked {
+			likedByViewer[id] = true
+		}
+	}
+
+	mentionRows, err := cfg.dbQueries.ListMentionsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mentionsByID := make(map[uuid.UUID][]mentionEntity, len(mentionRows))
+	for _, row := range mentionRows {
+		mentionsByID[row.ChirpID] = append(mentionsByID[row.ChirpID], mentionEntity{
+			UserID: row.UserID,
+			Handle: row.Handle.String,
+			Offset: row.StartOffset,
+			Length: row.Length,
+		})
+	}
+
+	var refByID map[uuid.UUID]chirpResponse
+	if expandRefs {
+		refByID, err = cfg.loadReferencedChirps(ctx, viewerID, chirps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, chirp := range chirps {
+		resp := chirpToResponse(chirp)
+		if expandRefs && chirp.RefKind.Valid {
+			resp.Reference = &chirpReference{Kind: chirp.RefKind.String, Deleted: true}
+			if ref, ok := refByID[chirp.RefChirpID.UUID]; ok && chirp.RefChirpID.Valid {
+				resp.Reference.Deleted = false
+				resp.Reference.Chirp = &ref
+			}
+		}
+		resp.LikeCount = countByID[chirp.ID]
+		if mentions, ok := mentionsByID[chirp.ID]; ok {
+			resp.Entities.Mentions = mentions
+		}
+		if viewerID.Valid {
+			liked := likedByViewer[chirp.ID]
+			resp.LikedByMe = &liked
+		}
+		resps = append(resps, resp)
+	}
+	return resps, nil
+}
+
+// buildChirpResponse is buildChirpResponses for a single chirp.
+func (cfg *apiConfig) buildChirpResponse(ctx context.Context, viewerID uuid.NullUUID, chirp database.Chirp) (chirpResponse, error) {
+	resps, err := cfg.buildChirpResponses(ctx, viewerID, []database.Chirp{chirp})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return resps[0], nil
+}
+
+// loadReferencedChirps fetches every chirp referenced by a rechirp or quote in
+// chirps, keyed by ID. Deleted originals are simply absent from the map.
+func (cfg *apiConfig) loadReferencedChirps(ctx context.Context, viewerID uuid.NullUUID, chirps []database.Chirp) (map[uuid.UUID]chirpResponse, error) {
+	refIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.RefChirpID.Valid {
+			refIDs = append(refIDs, chirp.RefChirpID.UUID)
+		}
+	}
+	if len(refIDs) == 0 {
+		return nil, nil
+	}
+
+	refChirps, err := cfg.dbQueries.GetChirpsByIDs(ctx, refIDs)
+	if err != nil {
+		return nil, err
+	}
+	refResps, err := cfg.buildChirpResponsesDepth(ctx, viewerID, refChirps, false)
+	if err != nil {
+		return nil, err
+	}
+
+	refByID := make(map[uuid.UUID]chirpResponse, len(refResps))
+	for _, resp := range refResps {
+		refByID[resp.ID] = resp
+	}
+	return refByID, nil
+}
@@ -3,11 +3,12 @@ package main
 import (
 	"chirpy-project/internal/auth"
 	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"regexp"
-	"sort"
 	"strings"
 	"time"
 
@@ -15,18 +16,52 @@ import (
 )
 
 type chirpRequest struct {
-	Body   string `json:"body"`
-	UserID string `json:"user_id"`
+	Body      string     `json:"body"`
+	UserID    string     `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	QuoteOf   *uuid.UUID `json:"quote_of"`
 }
 
 type chirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Body      string          `json:"body"`
+	UserID    uuid.UUID       `json:"user_id"`
+	ParentID  *uuid.UUID      `json:"parent_id,omitempty"`
+	RootID    *uuid.UUID      `json:"root_id,omitempty"`
+	LikeCount int64           `json:"like_count"`
+	LikedByMe *bool           `json:"liked_by_me,omitempty"`
+	Reference *chirpReference `json:"reference,omitempty"`
+	Entities  chirpEntities   `json:"entities"`
 }
 
+type chirpEntities struct {
+	Mentions []mentionEntity `json:"mentions"`
+}
+
+// mentionEntity locates an @handle in the chirp body. Offset and Length are
+// counted in Unicode code points and include the '@'.
+type mentionEntity struct {
+	UserID uuid.UUID `json:"user_id"`
+	Handle string    `json:"handle"`
+	Offset int32     `json:"offset"`
+	Length int32     `json:"length"`
+}
+
+// chirpReference is the chirp a rechirp or quote points at. Once the original
+// is deleted it is reported as a tombstone with Deleted set and no Chirp.
+type chirpReference struct {
+	Kind    string         `json:"kind"`
+	Deleted bool           `json:"deleted"`
+	Chirp   *chirpResponse `json:"chirp,omitempty"`
+}
+
+const (
+	refKindRechirp = "rechirp"
+	refKindQuote   = "quote"
+)
+
 type errorResponse struct {
 	Error string `json:"error"`
 }
@@ -41,9 +76,6 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 
 	defer r.Body.Close()
 
-	fmt.Printf("Request method: %s\n", r.Method)
-	fmt.Printf("Authorization header: %s\n", r.Header.Get("Authorization"))
-
 	// Parse the JWT from the Authorization header
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -53,10 +85,18 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validate the JWT
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	userID, err := cfg.authorizeToken(r.Context(), token, auth.ScopeChirpsWrite)
 	if err != nil {
-		fmt.Printf("ValidateJWT error: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		fmt.Printf("Authorization error: %v\n", err)
+		respondWithAuthError(w, err)
+		return
+	}
+
+	if ok, err := cfg.checkCanChirp(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	} else if !ok {
+		respondWithError(w, http.StatusForbidden, "Verify your email address to chirp")
 		return
 	}
 
@@ -68,41 +108,170 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate chirp length
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	if params.QuoteOf != nil && strings.TrimSpace(params.Body) == "" {
+		respondWithError(w, http.StatusBadRequest, "Quote must include a body")
 		return
 	}
 
-	// Filter profane words - improved case handling
-	cleanedBody := params.Body
-	for _, word := range profaneWords {
-		wordLower := strings.ToLower(word)
-		re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(wordLower))
-		cleanedBody = re.ReplaceAllString(cleanedBody, "****")
+	// Validate chirp length and filter profane words
+	cleanedBody, err := cleanChirpBody(params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// If this is a reply, link it to its parent and to the root of the thread
+	parentID := uuid.NullUUID{}
+	rootID := uuid.NullUUID{}
+	if params.InReplyTo != nil {
+		parent, err := cfg.dbQueries.GetChirp(r.Context(), *params.InReplyTo)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Chirp being replied to not found")
+			return
+		}
+		parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		rootID = parent.RootID
+		if !rootID.Valid {
+			rootID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		}
+	}
+
+	// If this is a quote, reference the original chirp being quoted
+	refChirpID := uuid.NullUUID{}
+	refKind := sql.NullString{}
+	if params.QuoteOf != nil {
+		quoted, err := cfg.dbQueries.GetChirp(r.Context(), *params.QuoteOf)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Chirp being quoted not found")
+			return
+		}
+		refChirpID = uuid.NullUUID{UUID: originalChirpID(quoted), Valid: true}
+		refKind = sql.NullString{String: refKindQuote, Valid: true}
 	}
 
 	// Create the chirp in the database, using the userID from the JWT
-	chirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userID, // Use userID from JWT
+	chirp, err := cfg.insertChirp(r.Context(), database.CreateChirpParams{
+		Body:       cleanedBody,
+		UserID:     userID, // Use userID from JWT
+		ParentID:   parentID,
+		RootID:     rootID,
+		RefChirpID: refChirpID,
+		RefKind:    refKind,
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to create chirp")
 		return
 	}
 
-	// Create the response
-	resp := chirpResponse{
+	resp, err := cfg.buildChirpResponse(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// insertChirp stores a new chirp together with the hashtags and mentions
+// extracted from its body, and notifies the replied-to and mentioned users,
+// in one transaction.
+func (cfg *apiConfig) insertChirp(ctx context.Context, params database.CreateChirpParams) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	chirp, err := qtx.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if err := storeChirpTags(ctx, qtx, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+
+	repliedTo := uuid.NullUUID{}
+	if chirp.ParentID.Valid {
+		parent, err := qtx.GetChirp(ctx, chirp.ParentID.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		if err := notify(ctx, qtx, parent.UserID, chirp.UserID, notificationKindReply, chirp.ID); err != nil {
+			return database.Chirp{}, err
+		}
+		repliedTo = uuid.NullUUID{UUID: parent.UserID, Valid: true}
+	}
+
+	mentioned, err := storeChirpMentions(ctx, qtx, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	for _, userID := range mentioned {
+		// The reply notification already tells them about this chirp
+		if repliedTo.Valid && userID == repliedTo.UUID {
+			continue
+		}
+		if err := notify(ctx, qtx, userID, chirp.UserID, notificationKindMention, chirp.ID); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	return chirp, tx.Commit()
+}
+
+// cleanChirpBody validates a chirp body and masks profane words. The error
+// message is safe to return to the client.
+func cleanChirpBody(body string) (string, error) {
+	// Validate chirp length
+	const maxChirpLength = 140
+	if len(body) > maxChirpLength {
+		return "", fmt.Errorf("Chirp is too long")
+	}
+	// Only rechirps have no body, and they don't come through here
+	if strings.TrimSpace(body) == "" {
+		return "", fmt.Errorf("Chirp cannot be empty")
+	}
+
+	// Filter profane words - improved case handling
+	cleanedBody := body
+	for _, word := range profaneWords {
+		wordLower := strings.ToLower(word)
+		re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(wordLower))
+		cleanedBody = re.ReplaceAllString(cleanedBody, "****")
+	}
+	return cleanedBody, nil
+}
+
+// chirpToResponse maps a database chirp onto the JSON shape returned by the API
+func chirpToResponse(chirp database.Chirp) chirpResponse {
+	return chirpResponse{
 		ID:        chirp.ID,
 		CreatedAt: chirp.CreatedAt,
 		UpdatedAt: chirp.UpdatedAt,
 		Body:      chirp.Body,
 		UserID:    chirp.UserID,
+		ParentID:  nullUUIDPtr(chirp.ParentID),
+		RootID:    nullUUIDPtr(chirp.RootID),
+		Entities:  chirpEntities{Mentions: []mentionEntity{}},
 	}
+}
 
-	respondWithJSON(w, http.StatusCreated, resp)
+// originalChirpID resolves a rechirp to the chirp it shares, so that sharing
+// or quoting a rechirp always points at the original author's chirp.
+func originalChirpID(chirp database.Chirp) uuid.UUID {
+	if chirp.RefKind.String == refKindRechirp && chirp.RefChirpID.Valid {
+		return chirp.RefChirpID.UUID
+	}
+	return chirp.ID
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
 }
 
 // Helper function to respond with an error
@@ -125,89 +294,77 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
+type chirpPageResponse struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+}
+
 func (cfg *apiConfig) listChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	sort_desc := false
+	query := r.URL.Query()
+
 	// Check if sorting decision is in the query parameters in the URL
-	sort_Type := r.URL.Query().Get("sort")
-	if sort_Type == "desc" {
-		sort_desc = true
-	}
+	sortDesc := query.Get("sort") == "desc"
+
 	// Check if author_id is in the query parameters in the URL
-	author_id := r.URL.Query().Get("author_id")
-	if author_id != "" {
-		parsed_author_id, err := uuid.Parse(author_id)
+	authorID := uuid.NullUUID{}
+	if raw := query.Get("author_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			errorResp := errorResponse{
-				Error: "Invalid author_id",
-			}
-			jsonResp, _ := json.Marshal(errorResp)
-			w.Write(jsonResp)
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id")
 			return
 		}
-		chirpResponses, err := cfg.dbQueries.ListChirpsByUser(r.Context(), parsed_author_id)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			errorResp := errorResponse{
-				Error: "Failed to list chirps from database",
-			}
-			jsonResp, _ := json.Marshal(errorResp)
-			w.Write(jsonResp)
-			return
-		}
-		chirps := []chirpResponse{}
-		for _, chirpRecord := range chirpResponses {
-			chirps = append(chirps, chirpResponse{
-				ID:        chirpRecord.ID,
-				CreatedAt: chirpRecord.CreatedAt,
-				UpdatedAt: chirpRecord.UpdatedAt,
-				Body:      chirpRecord.Body,
-				UserID:    chirpRecord.UserID,
-			})
-		}
-		if sort_desc {
-			sort.Slice(chirps, func(i, j int) bool {
-				return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-			})
-		}
-		w.WriteHeader(http.StatusOK)
-		jsonResp, _ := json.Marshal(chirps)
-		w.Write(jsonResp)
+		authorID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	limit, cursor, err := parsePageParams(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters")
 		return
+	}
 
+	chirpsDB, hasMore, err := cfg.fetchChirpPage(r.Context(), authorID, sortDesc, limit, cursor)
+	if err != nil {
+		fmt.Printf("Error listing chirps: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
 	}
 
-	chirpsDB, err := cfg.dbQueries.ListChirps(r.Context())
+	chirps, err := cfg.buildChirpResponses(r.Context(), cfg.optionalRequestUserID(r), chirpsDB)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		errorResp := errorResponse{
-			Error: "Failed to list chirps from database",
-		}
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
+		fmt.Printf("Error building chirp responses: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
 		return
 	}
 
-	chirps := []chirpResponse{}
+	resp := chirpPageResponse{Chirps: chirps}
+	keys := make([]pageKey, 0, len(chirpsDB))
 	for _, chirpDB := range chirpsDB {
-		chirps = append(chirps, chirpResponse{
-			ID:        chirpDB.ID,
-			CreatedAt: chirpDB.CreatedAt,
-			UpdatedAt: chirpDB.UpdatedAt,
-			Body:      chirpDB.Body,
-			UserID:    chirpDB.UserID,
-		})
+		keys = append(keys, pageKey{CreatedAt: chirpDB.CreatedAt, ID: chirpDB.ID})
 	}
+	resp.NextCursor, resp.PrevCursor = pageInfo(keys, cursor, hasMore)
 
-	if sort_desc {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// fetchChirpPage reads one page of chirps, optionally limited to one author.
+func (cfg *apiConfig) fetchChirpPage(ctx context.Context, authorID uuid.NullUUID, sortDesc bool, limit int, cursor *pageCursor) ([]database.Chirp, bool, error) {
+	return fetchPage(sortDesc, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]database.Chirp, error) {
+		if desc {
+			return cfg.dbQueries.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+				AuthorID:        authorID,
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+		}
+		return cfg.dbQueries.ListChirpsAsc(ctx, database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
 		})
-	}
-	w.WriteHeader(http.StatusOK)
-	jsonResp, _ := json.Marshal(chirps)
-	w.Write(jsonResp)
+	})
 }
 
 func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
@@ -246,12 +403,15 @@ func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp := chirpResponse{
-		ID:        chirpDB.ID,
-		CreatedAt: chirpDB.CreatedAt,
-		UpdatedAt: chirpDB.UpdatedAt,
-		Body:      chirpDB.Body,
-		UserID:    chirpDB.UserID,
+	chirp, err := cfg.buildChirpResponse(r.Context(), cfg.optionalRequestUserID(r), chirpDB)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		errorResp := errorResponse{
+			Error: "Failed to load chirp",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
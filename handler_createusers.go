@@ -1,13 +1,16 @@
 package main
 
 import (
-	"chirpy-project/internal/auth"
 	"chirpy-project/internal/database"
+	"chirpy-project/internal/entities"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type User struct {
@@ -16,11 +19,32 @@ type User struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Handle      string    `json:"handle,omitempty"`
+	// EmailVerified is about Email; PendingEmail is a requested change that
+	// has not been verified yet
+	EmailVerified bool   `json:"email_verified"`
+	PendingEmail  string `json:"pending_email,omitempty"`
+	Role          string `json:"role"`
+}
+
+func userToResponse(user database.User) User {
+	return User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		Handle:        user.Handle.String,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  user.PendingEmail.String,
+		Role:          user.Role,
+	}
 }
 
 type usersRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Handle   string `json:"handle"`
 }
 
 func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +82,17 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
+	if params.Handle != "" && !entities.ValidHandle(params.Handle) {
+		w.WriteHeader(http.StatusBadRequest)
+		errorResp := errorResponse{
+			Error: "Invalid handle",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(params.Password)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		errorResp := errorResponse{
@@ -72,9 +106,20 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 	user, err := cfg.dbQueries.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         sql.NullString{String: params.Handle, Valid: params.Handle != ""},
 	})
 
-	if err != nil && err.Error() == "UNIQUE constraint failed: users.email" {
+	if isHandleConflict(err) {
+		w.WriteHeader(http.StatusConflict)
+		errorResp := errorResponse{
+			Error: "Handle already taken",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+
+	if isEmailConflict(err) || (err != nil && err.Error() == "UNIQUE constraint failed: users.email") {
 		w.WriteHeader(http.StatusConflict)
 		errorResp := errorResponse{
 			Error: "User already exists",
@@ -94,16 +139,19 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
-	}
+	// New accounts start unverified until the emailed link is followed
+	go cfg.sendEmailVerification(user.ID, user.Email)
+
+	resp := userToResponse(user)
 
 	w.WriteHeader(http.StatusCreated)
 	jsonResp, _ := json.Marshal(resp)
 	w.Write(jsonResp)
 
 }
+
+// isHandleConflict reports whether err is the unique index on handles firing
+func isHandleConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_handle_lower_idx"
+}
@@ -25,7 +25,11 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	// Check the access token and get the user ID
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	ra, err := cfg.authenticateToken(r.Context(), token)
+	if err == nil && !ra.can(auth.ScopeChirpsWrite) {
+		respondWithAuthError(w, missingScopeError{scope: auth.ScopeChirpsWrite})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		errorResp := errorResponse{
@@ -64,8 +68,10 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// If chirp does not belong to user, return 403 status code
-	if chirprecord.UserID != userID {
+	// If chirp does not belong to user, return 403 status code, unless a
+	// moderator is taking it down
+	userID := ra.UserID
+	if chirprecord.UserID != userID && !ra.hasRole(auth.RoleModerator) {
 		w.WriteHeader(http.StatusForbidden)
 		errorResp := errorResponse{
 			Error: "Forbidden",
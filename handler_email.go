@@ -0,0 +1,202 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"chirpy-project/internal/mail"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const emailVerificationLifetime = 24 * time.Hour
+
+var (
+	errInvalidVerificationToken = errors.New("invalid or expired verification token")
+	errEmailTaken               = errors.New("email already in use")
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// verifyEmailHandler confirms an address with the token from a verification
+// email. For a new account that marks it verified; for an email change it
+// swaps the pending address in.
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	params := verifyEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := cfg.verifyEmail(r.Context(), auth.HashToken(params.Token))
+	if errors.Is(err, errInvalidVerificationToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token")
+		return
+	}
+	if errors.Is(err, errEmailTaken) {
+		respondWithError(w, http.StatusConflict, "Email already in use")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error verifying email: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userToResponse(user))
+}
+
+func (cfg *apiConfig) verifyEmail(ctx context.Context, tokenHash string) (database.User, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	verification, err := qtx.UseEmailVerification(ctx, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errInvalidVerificationToken
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if verification.ExpiresAt.Before(time.Now().UTC()) {
+		return database.User{}, errInvalidVerificationToken
+	}
+
+	user, err := qtx.GetUserByID(ctx, verification.UserID)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	switch {
+	case verification.Email == user.Email:
+		user, err = qtx.MarkEmailVerified(ctx, database.MarkEmailVerifiedParams{
+			ID:    user.ID,
+			Email: verification.Email,
+		})
+	case verification.Email == user.PendingEmail.String:
+		user, err = qtx.ConfirmPendingEmail(ctx, database.ConfirmPendingEmailParams{
+			ID:           user.ID,
+			PendingEmail: user.PendingEmail,
+		})
+		if isEmailConflict(err) {
+			return database.User{}, errEmailTaken
+		}
+		if err == nil {
+			// Links sent to addresses the user has moved on from stop working
+			err = qtx.InvalidateEmailVerifications(ctx, user.ID)
+		}
+	default:
+		// The token was for an address the user has since changed away from
+		return database.User{}, errInvalidVerificationToken
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	user, err = grantBootstrapAdmin(ctx, qtx, cfg.adminEmail, user)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+// resendVerificationHandler sends a new verification email for the caller's
+// pending address, or for their current one if it is not verified yet.
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	email := user.PendingEmail.String
+	if email == "" {
+		if user.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusBadRequest, "Email already verified")
+			return
+		}
+		email = user.Email
+	}
+
+	if !cfg.throttleMail(w, r, email) {
+		return
+	}
+
+	go cfg.sendEmailVerification(user.ID, email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendEmailVerification emails a verification link to email. It runs in the
+// background, so failures are only logged; the user can ask for a new link.
+func (cfg *apiConfig) sendEmailVerification(userID uuid.UUID, email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		fmt.Printf("Error generating verification token: %v\n", err)
+		return
+	}
+	err = cfg.dbQueries.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		fmt.Printf("Error storing verification token: %v\n", err)
+		return
+	}
+
+	link := cfg.baseURL + "/verify-email?token=" + url.QueryEscape(token)
+	err = cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your email for Chirpy",
+		Body: "Please confirm that this is your email address by opening this link within the next 24 hours:\n\n" +
+			link + "\n\n" +
+			"If you didn't sign up for Chirpy or change your email, you can ignore this email.\n",
+	})
+	if err != nil {
+		fmt.Printf("Error sending verification email: %v\n", err)
+	}
+}
+
+// checkCanChirp applies the REQUIRE_VERIFIED_EMAIL policy: when it is on,
+// only users with a verified email may post.
+func (cfg *apiConfig) checkCanChirp(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if !cfg.requireVerifiedEmail {
+		return true, nil
+	}
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt.Valid, nil
+}
+
+// isEmailConflict reports whether err is the unique constraint on emails firing
+func isEmailConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key"
+}
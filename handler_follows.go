@@ -0,0 +1,192 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followPageResponse struct {
+	Users      []followResponse `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	PrevCursor string           `json:"prev_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	followerID, err := cfg.authorizeRequest(r, auth.ScopeFollowsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if followeeID == followerID {
+		respondWithError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	// Make sure the user being followed exists, if not return 404
+	if _, err := cfg.dbQueries.GetUserByID(r.Context(), followeeID); err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	created, err := cfg.dbQueries.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		fmt.Printf("Error following user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to follow user")
+		return
+	}
+
+	// Only a new follow notifies; following again is a no-op
+	if created > 0 {
+		if err := notify(r.Context(), cfg.dbQueries, followeeID, followerID, notificationKindFollow, uuid.Nil); err != nil {
+			fmt.Printf("Error creating follow notification: %v\n", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	followerID, err := cfg.authorizeRequest(r, auth.ScopeFollowsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	err = cfg.dbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		fmt.Printf("Error unfollowing user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to unfollow user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollowGraph(w, r, cfg.fetchFollowers)
+}
+
+func (cfg *apiConfig) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollowGraph(w, r, cfg.fetchFollowing)
+}
+
+type followFetcher func(ctx context.Context, userID uuid.UUID, limit int, cursor *pageCursor) ([]followResponse, bool, error)
+
+// listFollowGraph serves one page of either side of a user's follow graph,
+// newest relationship first.
+func (cfg *apiConfig) listFollowGraph(w http.ResponseWriter, r *http.Request, fetch followFetcher) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters")
+		return
+	}
+
+	users, hasMore, err := fetch(r.Context(), userID, limit, cursor)
+	if err != nil {
+		fmt.Printf("Error listing follow graph: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list users from database")
+		return
+	}
+
+	resp := followPageResponse{Users: []followResponse{}}
+	keys := make([]pageKey, 0, len(users))
+	for _, user := range users {
+		resp.Users = append(resp.Users, user)
+		keys = append(keys, pageKey{CreatedAt: user.FollowedAt, ID: user.UserID})
+	}
+	resp.NextCursor, resp.PrevCursor = pageInfo(keys, cursor, hasMore)
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) fetchFollowers(ctx context.Context, userID uuid.UUID, limit int, cursor *pageCursor) ([]followResponse, bool, error) {
+	return fetchPage(true, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]followResponse, error) {
+		users := []followResponse{}
+		if desc {
+			rows, err := cfg.dbQueries.ListFollowersDesc(ctx, database.ListFollowersDescParams{
+				UserID:          userID,
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+			for _, row := range rows {
+				users = append(users, followResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+			}
+			return users, err
+		}
+		rows, err := cfg.dbQueries.ListFollowersAsc(ctx, database.ListFollowersAscParams{
+			UserID:          userID,
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
+		})
+		for _, row := range rows {
+			users = append(users, followResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+func (cfg *apiConfig) fetchFollowing(ctx context.Context, userID uuid.UUID, limit int, cursor *pageCursor) ([]followResponse, bool, error) {
+	return fetchPage(true, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]followResponse, error) {
+		users := []followResponse{}
+		if desc {
+			rows, err := cfg.dbQueries.ListFollowingDesc(ctx, database.ListFollowingDescParams{
+				UserID:          userID,
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+			for _, row := range rows {
+				users = append(users, followResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+			}
+			return users, err
+		}
+		rows, err := cfg.dbQueries.ListFollowingAsc(ctx, database.ListFollowingAscParams{
+			UserID:          userID,
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
+		})
+		for _, row := range rows {
+			users = append(users, followResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// loadJWTKeys builds the access token keyset from the environment.
+// JWT_SIGNING_KEY_FILE is the PEM private key new tokens are signed with and
+// JWT_VERIFICATION_KEY_FILES a comma-separated list of PEM keys that are still
+// trusted, such as the previous signing key during a rotation. Without a
+// signing key, tokens are signed with JWT_SECRET as before; while JWT_SECRET is
+// set, tokens it signed keep validating.
+func loadJWTKeys(secret string) (*auth.KeySet, error) {
+	keys := auth.NewKeySet(secret)
+
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); path != "" {
+		signingKey, err := auth.LoadSigningKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading JWT signing key: %w", err)
+		}
+		if _, err := keys.SetSigningKey(signingKey); err != nil {
+			return nil, fmt.Errorf("loading JWT signing key: %w", err)
+		}
+	}
+
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		publicKey, err := auth.LoadVerificationKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading JWT verification key: %w", err)
+		}
+		if _, err := keys.AddVerificationKey(publicKey); err != nil {
+			return nil, fmt.Errorf("loading JWT verification key %s: %w", path, err)
+		}
+	}
+	return keys, nil
+}
+
+// jwksHandler publishes the public keys access tokens can be verified with,
+// so other services do not need a shared secret.
+func (cfg *apiConfig) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.jwtKeys.JWKS())
+}
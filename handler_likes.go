@@ -0,0 +1,83 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type likeResponse struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	LikeCount int64     `json:"like_count"`
+	LikedByMe bool      `json:"liked_by_me"`
+}
+
+func (cfg *apiConfig) likeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.setChirpLike(w, r, true)
+}
+
+func (cfg *apiConfig) unlikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.setChirpLike(w, r, false)
+}
+
+// setChirpLike records or removes the caller's like. Both operations are
+// idempotent, so repeated or concurrent requests leave a single row at most.
+func (cfg *apiConfig) setChirpLike(w http.ResponseWriter, r *http.Request, like bool) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	if like {
+		var created int64
+		created, err = cfg.dbQueries.LikeChirp(r.Context(), database.LikeChirpParams{
+			ChirpID: chirpID,
+			UserID:  userID,
+		})
+		// Only a new like notifies; liking again is a no-op
+		if err == nil && created > 0 {
+			if err := notify(r.Context(), cfg.dbQueries, chirp.UserID, userID, notificationKindLike, chirpID); err != nil {
+				fmt.Printf("Error creating like notification: %v\n", err)
+			}
+		}
+	} else {
+		err = cfg.dbQueries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+			ChirpID: chirpID,
+			UserID:  userID,
+		})
+	}
+	if err != nil {
+		fmt.Printf("Error updating like: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update like")
+		return
+	}
+
+	resp, err := cfg.buildChirpResponse(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		fmt.Printf("Error counting likes: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to count likes")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, likeResponse{
+		ChirpID:   chirpID,
+		LikeCount: resp.LikeCount,
+		LikedByMe: like,
+	})
+}
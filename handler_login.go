@@ -3,9 +3,11 @@ package main
 import (
 	"chirpy-project/internal/auth"
 	"chirpy-project/internal/database"
-	"database/sql"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,6 +27,9 @@ type loginResponse struct {
 	Token         string    `json:"token"`
 	Refresh_Token string    `json:"refresh_token"`
 	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Handle        string    `json:"handle,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
 }
 
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -50,14 +55,36 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the username and password and expires time from params
 	username := params.Email
 	password := params.Password
+	ip := sessionClientFromRequest(r).IPAddress
 
-	// expires must always be 1 hour
-	params.Expires = 3600
-	expires := 3600
+	// Refuse before checking the password while the account or IP is locked
+	retryAfter, err := cfg.loginRetryAfter(r.Context(), username, ip)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		errorResp := errorResponse{
+			Error: "Failed to check login attempts",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		errorResp := errorResponse{
+			Error: "Too many failed login attempts, try again later",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
 
 	// Based on email, check if the user exists in the database
 	user, err := cfg.dbQueries.Login(r.Context(), username)
 	if err != nil {
+		if err := cfg.recordLoginFailure(r.Context(), username, ip, uuid.NullUUID{}); err != nil {
+			fmt.Printf("Error recording failed login: %v\n", err)
+		}
 		w.WriteHeader(http.StatusUnauthorized)
 		errorResp := errorResponse{
 			Error: "Incorrect email or password",
@@ -69,6 +96,9 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check if the password is correct
 	if err := auth.CheckPasswordHash(password, user.HashedPassword); err != nil {
+		if err := cfg.recordLoginFailure(r.Context(), username, ip, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+			fmt.Printf("Error recording failed login: %v\n", err)
+		}
 		w.WriteHeader(http.StatusUnauthorized)
 		errorResp := errorResponse{
 			Error: "Incorrect email or password",
@@ -78,36 +108,57 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Duration(expires)*time.Second)
+	// Upgrade hashes made with an older algorithm or weaker settings while
+	// we have the plaintext password in hand
+	if cfg.passwordHasher.NeedsRehash(user.HashedPassword) {
+		if err := cfg.rehashPassword(r.Context(), user.ID, password); err != nil {
+			fmt.Printf("Error upgrading password hash: %v\n", err)
+		}
+	}
+
+	// Accounts with two-factor authentication get a challenge instead of tokens
+	mfaRequired, err := cfg.userRequiresMFA(r.Context(), user.ID)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		errorResp := errorResponse{
-			Error: "Failed to generate token",
+			Error: "Failed to check two-factor authentication",
 		}
 		jsonResp, _ := json.Marshal(errorResp)
 		w.Write(jsonResp)
 		return
 	}
+	// Failures only clear once the second factor is in too, so a known
+	// password can't be used to reset the count while guessing codes
+	if mfaRequired {
+		cfg.respondWithMFAChallenge(w, r, user.ID)
+		return
+	}
 
-	// Generatea refresh token from auth
-	refreshtoken, err := auth.MakeRefreshToken()
+	if err := cfg.clearLoginFailures(r.Context(), username); err != nil {
+		fmt.Printf("Error clearing failed logins: %v\n", err)
+	}
+	cfg.respondWithLogin(w, r, user)
+}
+
+// respondWithLogin issues the access and refresh tokens for a user who has
+// passed every authentication step.
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	// expires must always be 1 hour
+	expires := 3600
+
+	token, err := cfg.jwtKeys.MakeJWT(user.ID, user.Role, time.Duration(expires)*time.Second)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		errorResp := errorResponse{
-			Error: "Failed to generate refresh token",
+			Error: "Failed to generate token",
 		}
 		jsonResp, _ := json.Marshal(errorResp)
 		w.Write(jsonResp)
 		return
 	}
 
-	// Insert the refresh token into the database
-	_, err = cfg.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshtoken,
-		ExpiresAt: time.Now().Add(time.Duration(1440) * time.Hour),
-		RevokedAt: sql.NullTime{},
-	})
+	// Generate a refresh token, starting a new token family
+	refreshtoken, err := issueRefreshToken(r.Context(), cfg.dbQueries, user.ID, uuid.New(), sessionClientFromRequest(r), refreshTokenGrant{})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		errorResp := errorResponse{
@@ -126,6 +177,9 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		Token:         token,
 		Refresh_Token: refreshtoken,
 		IsChirpyRed:   user.IsChirpyRed,
+		Handle:        user.Handle.String,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Role:          user.Role,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"fmt"
+	"net/http"
+)
+
+// clearLoginLockHandler lets an admin lift a login lockout early, for an
+// account (?email=) and/or an IP (?ip=). The failure count goes with it.
+func (cfg *apiConfig) clearLoginLockHandler(w http.ResponseWriter, r *http.Request) {
+	var targets []database.ClearLoginThrottleParams
+	if email := r.URL.Query().Get("email"); email != "" {
+		targets = append(targets, database.ClearLoginThrottleParams{Scope: loginThrottleAccount, Subject: loginAccountKey(email)})
+	}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		targets = append(targets, database.ClearLoginThrottleParams{Scope: loginThrottleIP, Subject: ip})
+	}
+	if len(targets) == 0 {
+		respondWithError(w, http.StatusBadRequest, "email or ip is required")
+		return
+	}
+
+	var cleared int64
+	for _, target := range targets {
+		n, err := cfg.dbQueries.ClearLoginThrottle(r.Context(), target)
+		if err != nil {
+			fmt.Printf("Error clearing login lock: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to clear login lock")
+			return
+		}
+		cleared += n
+	}
+	if cleared == 0 {
+		respondWithError(w, http.StatusNotFound, "No failed logins recorded")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer = "Chirpy"
+	// totpSkew is how many 30 second steps of clock drift are tolerated
+	totpSkew          = 1
+	recoveryCodeCount = 10
+
+	mfaChallengeLifetime = 5 * time.Minute
+	// mfaMaxAttempts bounds guessing at a 6 digit code with one challenge
+	mfaMaxAttempts = 5
+)
+
+var errInvalidSecondFactor = errors.New("invalid second factor")
+
+type totpEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// secondFactorRequest carries either a code from the authenticator app or
+// one of the recovery codes.
+type secondFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type mfaChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	secondFactorRequest
+}
+
+// enrollTOTPHandler starts two-factor enrollment with a fresh secret. It only
+// takes effect once verifyTOTPHandler has seen a code generated from it.
+func (cfg *apiConfig) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+
+	stored, err := cfg.dbQueries.SetPendingTOTP(r.Context(), database.SetPendingTOTPParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		fmt.Printf("Error storing TOTP secret: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+	if stored == 0 {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, totpEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, totpIssuer, user.Email),
+	})
+}
+
+// verifyTOTPHandler finishes enrollment with the first code from the
+// authenticator app and hands out the recovery codes. They are only ever
+// shown here.
+func (cfg *apiConfig) verifyTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := secondFactorRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	totp, err := cfg.dbQueries.GetUserTOTP(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Two-factor enrollment has not been started")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error loading TOTP secret: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if totp.EnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	key, err := auth.DecodeTOTPSecret(totp.Secret)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	step, ok := auth.DefaultTOTP.Validate(key, params.Code, time.Now(), totpSkew)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := cfg.enableTOTP(r.Context(), userID, step)
+	if err != nil {
+		fmt.Printf("Error enabling TOTP: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// enableTOTP turns two-factor authentication on and replaces the recovery
+// codes, returning the new ones in the clear.
+func (cfg *apiConfig) enableTOTP(ctx context.Context, userID uuid.UUID, step int64) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashRecoveryCode(code))
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	enabled, err := qtx.EnableUserTOTP(ctx, database.EnableUserTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if enabled == 0 {
+		return nil, errors.New("two-factor authentication enabled concurrently")
+	}
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	err = qtx.AddRecoveryCodes(ctx, database.AddRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// disableTOTPHandler turns two-factor authentication off. It asks for a
+// current code so a stolen access token is not enough.
+func (cfg *apiConfig) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := secondFactorRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !cfg.checkSecondFactor(w, r, user, params) {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+	if err := qtx.DeleteUserTOTP(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userRequiresMFA reports whether the user has finished enrolling in
+// two-factor authentication.
+func (cfg *apiConfig) userRequiresMFA(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := cfg.dbQueries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.EnabledAt.Valid, nil
+}
+
+// respondWithMFAChallenge answers a correct password on an account with
+// two-factor authentication. The challenge token is exchanged, along with a
+// code, at POST /api/login/mfa.
+func (cfg *apiConfig) respondWithMFAChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(mfaChallengeLifetime)
+	err = cfg.dbQueries.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		fmt.Printf("Error creating MFA challenge: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mfaChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// mfaLoginHandler is the second step of logging in to an account with
+// two-factor authentication. A challenge is good for one successful login or
+// mfaMaxAttempts wrong codes, whichever comes first.
+func (cfg *apiConfig) mfaLoginHandler(w http.ResponseWriter, r *http.Request) {
+	params := mfaLoginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	tokenHash := auth.HashToken(params.MFAToken)
+	challenge, err := cfg.dbQueries.RecordMFAChallengeAttempt(r.Context(), tokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	if challenge.ExpiresAt.Before(time.Now().UTC()) || challenge.Attempts > mfaMaxAttempts {
+		cfg.dbQueries.DeleteMFAChallenge(r.Context(), tokenHash)
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if !cfg.checkSecondFactor(w, r, user, params.secondFactorRequest) {
+		return
+	}
+
+	if err := cfg.dbQueries.DeleteMFAChallenge(r.Context(), tokenHash); err != nil {
+		fmt.Printf("Error deleting MFA challenge: %v\n", err)
+	}
+
+	cfg.respondWithLogin(w, r, user)
+}
+
+// checkSecondFactor verifies a code for user under the same throttling as
+// passwords, since a fresh challenge or access token is otherwise all it
+// takes to keep guessing. Wrong codes count as failed logins for the account
+// and IP, and a correct one clears the account's failures. It reports
+// whether the code was accepted, having responded already if not.
+func (cfg *apiConfig) checkSecondFactor(w http.ResponseWriter, r *http.Request, user database.User, params secondFactorRequest) bool {
+	ip := sessionClientFromRequest(r).IPAddress
+
+	retryAfter, err := cfg.loginRetryAfter(r.Context(), user.Email, ip)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check login attempts")
+		return false
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed attempts, try again later")
+		return false
+	}
+
+	err = cfg.verifySecondFactor(r.Context(), user.ID, params)
+	if errors.Is(err, errInvalidSecondFactor) {
+		if err := cfg.recordLoginFailure(r.Context(), user.Email, ip, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+			fmt.Printf("Error recording failed login: %v\n", err)
+		}
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return false
+	}
+	if err != nil {
+		fmt.Printf("Error verifying second factor: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return false
+	}
+
+	if err := cfg.clearLoginFailures(r.Context(), user.Email); err != nil {
+		fmt.Printf("Error clearing failed logins: %v\n", err)
+	}
+	return true
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code. Either can only
+// be used once: recovery codes are marked used, and a TOTP code is refused
+// unless it is for a later time step than the last one accepted.
+func (cfg *apiConfig) verifySecondFactor(ctx context.Context, userID uuid.UUID, params secondFactorRequest) error {
+	totp, err := cfg.dbQueries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidSecondFactor
+	}
+	if err != nil {
+		return err
+	}
+	if !totp.EnabledAt.Valid {
+		return errInvalidSecondFactor
+	}
+
+	if params.RecoveryCode != "" {
+		used, err := cfg.dbQueries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(params.RecoveryCode),
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	key, err := auth.DecodeTOTPSecret(totp.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := auth.DefaultTOTP.Validate(key, params.Code, time.Now(), totpSkew)
+	if !ok {
+		return errInvalidSecondFactor
+	}
+	used, err := cfg.dbQueries.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
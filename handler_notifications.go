@@ -0,0 +1,169 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type notificationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Read      bool       `json:"read"`
+}
+
+func notificationToResponse(notification database.Notification) notificationResponse {
+	return notificationResponse{
+		ID:        notification.ID,
+		Kind:      notification.Kind,
+		ActorID:   notification.ActorID,
+		ChirpID:   nullUUIDPtr(notification.ChirpID),
+		CreatedAt: notification.CreatedAt,
+		Read:      notification.ReadAt.Valid,
+	}
+}
+
+// notificationPageResponse is a page of notifications. ReadCursor points at
+// the newest notification on the page and can be sent to
+// POST /api/notifications/read to mark everything up to it as read.
+type notificationPageResponse struct {
+	Notifications []notificationResponse `json:"notifications"`
+	NextCursor    string                 `json:"next_cursor,omitempty"`
+	PrevCursor    string                 `json:"prev_cursor,omitempty"`
+	ReadCursor    string                 `json:"read_cursor,omitempty"`
+}
+
+type markNotificationsReadRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+type unreadCountResponse struct {
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// listNotificationsHandler returns the caller's notifications, newest first.
+// Pass unread=true to only see notifications that have not been read yet.
+func (cfg *apiConfig) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeNotificationsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	limit, cursor, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters")
+		return
+	}
+
+	notifications, hasMore, err := fetchPage(true, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]database.Notification, error) {
+		if desc {
+			return cfg.dbQueries.ListNotificationsDesc(r.Context(), database.ListNotificationsDescParams{
+				UserID:          userID,
+				UnreadOnly:      unreadOnly,
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+		}
+		return cfg.dbQueries.ListNotificationsAsc(r.Context(), database.ListNotificationsAscParams{
+			UserID:          userID,
+			UnreadOnly:      unreadOnly,
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
+		})
+	})
+	if err != nil {
+		fmt.Printf("Error listing notifications: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list notifications from database")
+		return
+	}
+
+	resp := notificationPageResponse{Notifications: []notificationResponse{}}
+	keys := make([]pageKey, 0, len(notifications))
+	for _, notification := range notifications {
+		resp.Notifications = append(resp.Notifications, notificationToResponse(notification))
+		keys = append(keys, pageKey{CreatedAt: notification.CreatedAt, ID: notification.ID})
+	}
+	resp.NextCursor, resp.PrevCursor = pageInfo(keys, cursor, hasMore)
+	if len(keys) > 0 {
+		resp.ReadCursor = encodeCursor(keys[0], false)
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// markNotificationsReadHandler marks the caller's notifications as read, up
+// to and including the one the cursor points at. Without a cursor every
+// notification is marked as read.
+func (cfg *apiConfig) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeNotificationsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// The body is optional, so an empty one is not an error
+	params := markNotificationsReadRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var cursor *pageCursor
+	if params.Cursor != "" {
+		decoded, err := decodeCursor(params.Cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		cursor = &decoded
+	}
+
+	_, err = cfg.dbQueries.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID:          userID,
+		CursorCreatedAt: cursor.createdAtParam(),
+		CursorID:        cursor.idParam(),
+	})
+	if err != nil {
+		fmt.Printf("Error marking notifications read: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark notifications as read")
+		return
+	}
+
+	cfg.respondWithUnreadCount(w, r, userID)
+}
+
+func (cfg *apiConfig) unreadNotificationCountHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeNotificationsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	cfg.respondWithUnreadCount(w, r, userID)
+}
+
+func (cfg *apiConfig) respondWithUnreadCount(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	count, err := cfg.dbQueries.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error counting unread notifications: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, unreadCountResponse{UnreadCount: count})
+}
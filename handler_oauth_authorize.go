@@ -0,0 +1,383 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const oauthCodeLifetime = 10 * time.Minute
+
+// scopeDescriptions is how each scope is explained on the consent page.
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:        "Read chirps and your timeline",
+	auth.ScopeChirpsWrite:       "Post, edit, delete and like chirps as you",
+	auth.ScopeProfileRead:       "See your Chirpy Red subscription",
+	auth.ScopeProfileWrite:      "Change your handle",
+	auth.ScopeFollowsWrite:      "Follow and unfollow people as you",
+	auth.ScopeNotificationsRead: "Read your notifications",
+}
+
+var (
+	errInvalidOAuthClient = errors.New("unknown OAuth client")
+	errInvalidRedirectURI = errors.New("redirect URI not registered for client")
+	errConsentLoginFailed = errors.New("incorrect email or password")
+	errConsentNeedsCode   = errors.New("two-factor code required")
+)
+
+// oauthError is an error response as defined by RFC 6749, sent either as
+// JSON from the token endpoint or as parameters on the redirect URI.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// oauthAuthorization is a checked request to the authorization endpoint.
+type oauthAuthorization struct {
+	Client           database.OauthClient
+	RedirectURI      string
+	RedirectURIGiven bool
+	Scopes           []string
+	State            string
+	CodeChallenge    string
+}
+
+// parseAuthorization checks an authorization request. A bad client or
+// redirect URI yields errInvalidOAuthClient or errInvalidRedirectURI, which
+// must be shown to the user rather than sent anywhere; any other problem is
+// an *oauthError for the client, with the redirect URI and state filled in.
+func (cfg *apiConfig) parseAuthorization(ctx context.Context, params url.Values) (oauthAuthorization, error) {
+	authz := oauthAuthorization{State: params.Get("state")}
+
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		return authz, errInvalidOAuthClient
+	}
+	authz.Client, err = cfg.dbQueries.GetOAuthClient(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return authz, errInvalidOAuthClient
+	}
+	if err != nil {
+		return authz, err
+	}
+
+	// The redirect URI must match a registered one exactly; it may only be
+	// left out when there is just one, and then the token request may leave
+	// it out too
+	authz.RedirectURI = params.Get("redirect_uri")
+	authz.RedirectURIGiven = authz.RedirectURI != ""
+	if authz.RedirectURI == "" && len(authz.Client.RedirectUris) == 1 {
+		authz.RedirectURI = authz.Client.RedirectUris[0]
+	}
+	if !slices.Contains(authz.Client.RedirectUris, authz.RedirectURI) {
+		return authz, errInvalidRedirectURI
+	}
+
+	if params.Get("response_type") != "code" {
+		return authz, &oauthError{"unsupported_response_type", "Only the authorization code flow is supported"}
+	}
+	authz.Scopes, err = auth.ParseScopes(strings.Fields(params.Get("scope")))
+	if err != nil {
+		return authz, &oauthError{"invalid_scope", err.Error()}
+	}
+	authz.CodeChallenge = params.Get("code_challenge")
+	if params.Get("code_challenge_method") != "S256" || !auth.ValidPKCEChallenge(authz.CodeChallenge) {
+		return authz, &oauthError{"invalid_request", "PKCE with code_challenge_method S256 is required"}
+	}
+	return authz, nil
+}
+
+// authorizeHandler shows the consent page for an app asking to act for the
+// user. The user signs in on the page itself, since the API has no cookie
+// sessions.
+func (cfg *apiConfig) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	authz, err := cfg.parseAuthorization(r.Context(), r.URL.Query())
+	if err != nil {
+		respondToAuthorizationError(w, r, authz, err)
+		return
+	}
+	renderConsentPage(w, http.StatusOK, authz, "", "")
+}
+
+// authorizeDecisionHandler takes the consent form. Approving, with the
+// user's credentials, sends the app back an authorization code; denying
+// sends it access_denied.
+func (cfg *apiConfig) authorizeDecisionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The request could not be read.")
+		return
+	}
+	authz, err := cfg.parseAuthorization(r.Context(), r.PostForm)
+	if err != nil {
+		respondToAuthorizationError(w, r, authz, err)
+		return
+	}
+
+	if r.PostForm.Get("action") != "approve" {
+		redirectToClient(w, r, authz, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	user, err := cfg.authenticateConsent(r, email, r.PostForm.Get("password"), r.PostForm.Get("code"))
+	var retry retryAfterError
+	switch {
+	case errors.As(err, &retry):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.wait.Seconds()))))
+		renderConsentPage(w, http.StatusTooManyRequests, authz, email, "Too many failed login attempts, try again later.")
+		return
+	case errors.Is(err, errConsentLoginFailed):
+		renderConsentPage(w, http.StatusUnauthorized, authz, email, "Incorrect email or password.")
+		return
+	case errors.Is(err, errConsentNeedsCode):
+		renderConsentPage(w, http.StatusUnauthorized, authz, email, "Enter the code from your authenticator app or a recovery code.")
+		return
+	case errors.Is(err, errInvalidSecondFactor):
+		renderConsentPage(w, http.StatusUnauthorized, authz, email, "Invalid two-factor code.")
+		return
+	case err != nil:
+		fmt.Printf("Error authenticating OAuth consent: %v\n", err)
+		renderOAuthErrorPage(w, http.StatusInternalServerError, "Something went wrong, please try again.")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		renderOAuthErrorPage(w, http.StatusInternalServerError, "Something went wrong, please try again.")
+		return
+	}
+	err = cfg.dbQueries.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:         auth.HashToken(code),
+		ClientID:         authz.Client.ID,
+		UserID:           user.ID,
+		RedirectUri:      authz.RedirectURI,
+		RedirectUriGiven: authz.RedirectURIGiven,
+		Scopes:           authz.Scopes,
+		CodeChallenge:    authz.CodeChallenge,
+		FamilyID:         uuid.New(),
+		ExpiresAt:        time.Now().UTC().Add(oauthCodeLifetime),
+	})
+	if err != nil {
+		fmt.Printf("Error storing OAuth authorization code: %v\n", err)
+		renderOAuthErrorPage(w, http.StatusInternalServerError, "Something went wrong, please try again.")
+		return
+	}
+
+	redirectToClient(w, r, authz, url.Values{"code": {code}})
+}
+
+// retryAfterError refuses a login attempt while failures are throttled.
+type retryAfterError struct {
+	wait time.Duration
+}
+
+func (e retryAfterError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry in %s", e.wait)
+}
+
+// authenticateConsent checks the credentials typed into the consent page,
+// with the same throttling and second factor as logging in.
+func (cfg *apiConfig) authenticateConsent(r *http.Request, email, password, code string) (database.User, error) {
+	ctx := r.Context()
+	ip := sessionClientFromRequest(r).IPAddress
+
+	wait, err := cfg.loginRetryAfter(ctx, email, ip)
+	if err != nil {
+		return database.User{}, err
+	}
+	if wait > 0 {
+		return database.User{}, retryAfterError{wait: wait}
+	}
+
+	user, err := cfg.dbQueries.Login(ctx, email)
+	if err != nil {
+		if err := cfg.recordLoginFailure(ctx, email, ip, uuid.NullUUID{}); err != nil {
+			fmt.Printf("Error recording failed login: %v\n", err)
+		}
+		return database.User{}, errConsentLoginFailed
+	}
+	if err := auth.CheckPasswordHash(password, user.HashedPassword); err != nil {
+		if err := cfg.recordLoginFailure(ctx, email, ip, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+			fmt.Printf("Error recording failed login: %v\n", err)
+		}
+		return database.User{}, errConsentLoginFailed
+	}
+
+	mfaRequired, err := cfg.userRequiresMFA(ctx, user.ID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if mfaRequired {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			return database.User{}, errConsentNeedsCode
+		}
+		// Recovery codes are the ones with a dash in the middle
+		second := secondFactorRequest{Code: code}
+		if strings.Contains(code, "-") {
+			second = secondFactorRequest{RecoveryCode: code}
+		}
+		if err := cfg.verifySecondFactor(ctx, user.ID, second); err != nil {
+			if errors.Is(err, errInvalidSecondFactor) {
+				if err := cfg.recordLoginFailure(ctx, email, ip, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+					fmt.Printf("Error recording failed login: %v\n", err)
+				}
+			}
+			return database.User{}, err
+		}
+	}
+
+	if err := cfg.clearLoginFailures(ctx, email); err != nil {
+		fmt.Printf("Error clearing failed logins: %v\n", err)
+	}
+	return user, nil
+}
+
+// respondToAuthorizationError reports a bad authorization request: to the
+// client if it and its redirect URI check out, otherwise to the user.
+func respondToAuthorizationError(w http.ResponseWriter, r *http.Request, authz oauthAuthorization, err error) {
+	var oauthErr *oauthError
+	switch {
+	case errors.As(err, &oauthErr):
+		redirectToClient(w, r, authz, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+		})
+	case errors.Is(err, errInvalidOAuthClient):
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The app asking for access is not registered with Chirpy.")
+	case errors.Is(err, errInvalidRedirectURI):
+		renderOAuthErrorPage(w, http.StatusBadRequest, "The app asked to return to an address it has not registered.")
+	default:
+		fmt.Printf("Error checking OAuth authorization request: %v\n", err)
+		renderOAuthErrorPage(w, http.StatusInternalServerError, "Something went wrong, please try again.")
+	}
+}
+
+// redirectToClient sends the browser back to the app with params and the
+// state it passed in.
+func redirectToClient(w http.ResponseWriter, r *http.Request, authz oauthAuthorization, params url.Values) {
+	// Registered redirect URIs were checked when the client was created
+	target, _ := url.Parse(authz.RedirectURI)
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if authz.State != "" {
+		query.Set("state", authz.State)
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+type consentScope struct {
+	Name        string
+	Description string
+}
+
+type consentPageData struct {
+	ClientName string
+	Scopes     []consentScope
+	Fields     map[string]string
+	Email      string
+	Error      string
+}
+
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorize {{.ClientName}} - Chirpy</title></head>
+<body>
+<h1>Authorize {{.ClientName}}</h1>
+<p><strong>{{.ClientName}}</strong> would like to:</p>
+<ul>
+{{range .Scopes}}<li>{{.Description}} <small>({{.Name}})</small></li>
+{{end}}</ul>
+{{if .Error}}<p role="alert"><strong>{{.Error}}</strong></p>{{end}}
+<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Fields}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+<p><label>Two-factor code, if enabled <input type="text" name="code" autocomplete="one-time-code"></label></p>
+<p><button type="submit" name="action" value="approve">Allow</button>
+<button type="submit" name="action" value="deny" formnovalidate>Deny</button></p>
+</form>
+</body>
+</html>
+`))
+
+var oauthErrorPage = template.Must(template.New("oauth-error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorization failed - Chirpy</title></head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+// setOAuthPageHeaders keeps the consent page out of frames and caches, so
+// it cannot be clickjacked or replayed. There is no form-action: browsers
+// apply it to the redirect that follows the form post, which goes to the
+// client's redirect URI.
+func setOAuthPageHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+}
+
+func renderConsentPage(w http.ResponseWriter, status int, authz oauthAuthorization, email, problem string) {
+	// Pass the redirect URI on only as given, so the code records whether
+	// the token request has to repeat it
+	redirectURI := ""
+	if authz.RedirectURIGiven {
+		redirectURI = authz.RedirectURI
+	}
+	data := consentPageData{
+		ClientName: authz.Client.Name,
+		Email:      email,
+		Error:      problem,
+		Fields: map[string]string{
+			"response_type":         "code",
+			"client_id":             authz.Client.ID.String(),
+			"redirect_uri":          redirectURI,
+			"scope":                 strings.Join(authz.Scopes, " "),
+			"state":                 authz.State,
+			"code_challenge":        authz.CodeChallenge,
+			"code_challenge_method": "S256",
+		},
+	}
+	for _, scope := range authz.Scopes {
+		data.Scopes = append(data.Scopes, consentScope{Name: scope, Description: scopeDescriptions[scope]})
+	}
+
+	setOAuthPageHeaders(w)
+	w.WriteHeader(status)
+	if err := consentPage.Execute(w, data); err != nil {
+		fmt.Printf("Error rendering consent page: %v\n", err)
+	}
+}
+
+func renderOAuthErrorPage(w http.ResponseWriter, status int, message string) {
+	setOAuthPageHeaders(w)
+	w.WriteHeader(status)
+	if err := oauthErrorPage.Execute(w, message); err != nil {
+		fmt.Printf("Error rendering OAuth error page: %v\n", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxOAuthClientNameLength = 100
+	maxOAuthRedirectURIs     = 10
+)
+
+type createOAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// Public clients, such as browser and mobile apps, cannot keep a secret
+	// and get none
+	Public bool `json:"public"`
+}
+
+// oauthClientResponse describes a registered client. ClientSecret is only
+// filled in when a confidential client is registered.
+type oauthClientResponse struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+}
+
+func oauthClientToResponse(client database.OauthClient) oauthClientResponse {
+	return oauthClientResponse{
+		ClientID:     client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Public:       !client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// createOAuthClientHandler registers a third-party app owned by the caller.
+func (cfg *apiConfig) createOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := createOAuthClientRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > maxOAuthClientNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Client name must be 1 to %d characters", maxOAuthClientNameLength))
+		return
+	}
+	if len(params.RedirectURIs) == 0 || len(params.RedirectURIs) > maxOAuthRedirectURIs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Between 1 and %d redirect URIs are required", maxOAuthRedirectURIs))
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if !validRedirectURI(uri) {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect URI: "+uri)
+			return
+		}
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if !params.Public {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to generate client secret")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.dbQueries.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userID,
+		Name:         name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+	})
+	if err != nil {
+		fmt.Printf("Error creating OAuth client: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to register client")
+		return
+	}
+
+	resp := oauthClientToResponse(client)
+	resp.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// listOAuthClientsHandler returns the clients the caller has registered.
+func (cfg *apiConfig) listOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	clients, err := cfg.dbQueries.ListOAuthClients(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error listing OAuth clients: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list clients")
+		return
+	}
+
+	resp := []oauthClientResponse{}
+	for _, client := range clients {
+		resp = append(resp, oauthClientToResponse(client))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// deleteOAuthClientHandler removes one of the caller's clients, along with
+// every code and refresh token issued to it.
+func (cfg *apiConfig) deleteOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid client ID")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:      clientID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		fmt.Printf("Error deleting OAuth client: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete client")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Client not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validRedirectURI accepts the redirect URIs RFC 8252 allows: https, http
+// to a loopback address for native apps, and private-use schemes named
+// after a domain (com.example.app:/callback). Fragments are not allowed.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	default:
+		return strings.Contains(u.Scheme, ".")
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const oauthAccessTokenLifetime = time.Hour
+
+var errInvalidGrant = errors.New("invalid authorization grant")
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// oauthTokenHandler is the OAuth token endpoint. It redeems authorization
+// codes and rotates OAuth refresh tokens, for the authenticated client only.
+func (cfg *apiConfig) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Invalid form body"})
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, http.StatusUnauthorized, &oauthError{"invalid_client", "Client authentication failed"})
+		return
+	}
+
+	var resp oauthTokenResponse
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		resp, err = cfg.redeemAuthorizationCode(r.Context(), client, r.PostForm, sessionClientFromRequest(r))
+	case "refresh_token":
+		resp, err = cfg.refreshOAuthToken(r.Context(), client, r.PostForm, sessionClientFromRequest(r))
+	default:
+		err = &oauthError{"unsupported_grant_type", "Supported grant types are authorization_code and refresh_token"}
+	}
+
+	var oauthErr *oauthError
+	switch {
+	case errors.As(err, &oauthErr):
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErr)
+	case errors.Is(err, errInvalidGrant):
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_grant", "The grant is invalid, expired or was already used"})
+	case err != nil:
+		fmt.Printf("Error issuing OAuth tokens: %v\n", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{"server_error", "Failed to issue tokens"})
+	default:
+		respondWithJSON(w, http.StatusOK, resp)
+	}
+}
+
+// redeemAuthorizationCode trades a code for the first access and refresh
+// tokens of the grant. Redeeming a code twice revokes whatever the first
+// redemption produced, since one of the two parties had a stolen code.
+func (cfg *apiConfig) redeemAuthorizationCode(ctx context.Context, client database.OauthClient, params url.Values, device sessionClient) (oauthTokenResponse, error) {
+	codeHash := auth.HashToken(params.Get("code"))
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	code, err := qtx.UseOAuthAuthorizationCode(ctx, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.handleAuthorizationCodeReuse(ctx, codeHash)
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	if code.ClientID != client.ID || code.ExpiresAt.Before(time.Now().UTC()) {
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	// The redirect URI has to be repeated only if the authorization request
+	// named it, but a client that sends one anyway must send the right one
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI != code.RedirectUri && (code.RedirectUriGiven || redirectURI != "") {
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	if !auth.VerifyPKCE(params.Get("code_verifier"), code.CodeChallenge) {
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+
+	refreshToken, err := issueRefreshToken(ctx, qtx, code.UserID, code.FamilyID, device, refreshTokenGrant{
+		ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+		Scopes:   code.Scopes,
+	})
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	resp, err := cfg.oauthTokens(code.UserID, client, code.Scopes, refreshToken)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	return resp, tx.Commit()
+}
+
+// handleAuthorizationCodeReuse revokes the refresh tokens issued for a code
+// that is presented again. Failures are only logged; the caller is refused
+// either way.
+func (cfg *apiConfig) handleAuthorizationCodeReuse(ctx context.Context, codeHash string) {
+	code, err := cfg.dbQueries.GetOAuthAuthorizationCode(ctx, codeHash)
+	if err != nil {
+		// Not a code we ever issued
+		return
+	}
+	revoked, err := cfg.dbQueries.RevokeRefreshTokenFamily(ctx, code.FamilyID)
+	if err != nil {
+		fmt.Printf("Error revoking refresh token family: %v\n", err)
+		return
+	}
+	detail := fmt.Sprintf("authorization code for client %s redeemed twice; %d refresh tokens revoked", code.ClientID, revoked)
+	if err := logSecurityEvent(ctx, cfg.dbQueries, code.UserID, securityEventOAuthCodeReuse, detail); err != nil {
+		fmt.Printf("Error logging security event: %v\n", err)
+	}
+}
+
+// refreshOAuthToken rotates an OAuth refresh token. The new access token
+// may ask for fewer scopes than were granted; the refresh token keeps them
+// all.
+func (cfg *apiConfig) refreshOAuthToken(ctx context.Context, client database.OauthClient, params url.Values, device sessionClient) (oauthTokenResponse, error) {
+	current, err := cfg.dbQueries.GetUserFromRefreshToken(ctx, params.Get("refresh_token"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	if !current.ClientID.Valid || current.ClientID.UUID != client.ID {
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	if current.RevokedAt.Valid {
+		cfg.handleRefreshTokenReuse(ctx, current)
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	if current.ExpiresAt.Before(time.Now()) {
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+
+	scopes := current.Scopes
+	if requested := strings.Fields(params.Get("scope")); len(requested) > 0 {
+		scopes, err = auth.ParseScopes(requested)
+		if err != nil {
+			return oauthTokenResponse{}, &oauthError{"invalid_scope", err.Error()}
+		}
+		for _, scope := range scopes {
+			if !auth.HasScope(current.Scopes, scope) {
+				return oauthTokenResponse{}, &oauthError{"invalid_scope", "Scope " + scope + " was not granted"}
+			}
+		}
+	}
+
+	refreshToken, err := cfg.rotateRefreshToken(ctx, current, device)
+	if errors.Is(err, errRefreshTokenReused) {
+		cfg.handleRefreshTokenReuse(ctx, current)
+		return oauthTokenResponse{}, errInvalidGrant
+	}
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	return cfg.oauthTokens(current.UserID, client, scopes, refreshToken)
+}
+
+func (cfg *apiConfig) oauthTokens(userID uuid.UUID, client database.OauthClient, scopes []string, refreshToken string) (oauthTokenResponse, error) {
+	accessToken, err := cfg.jwtKeys.MakeScopedJWT(userID, client.ID.String(), scopes, oauthAccessTokenLifetime)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	return oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenLifetime / time.Second),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// oauthRevokeHandler is the RFC 7009 revocation endpoint. Revoking a
+// refresh token ends the whole grant. Access tokens are not stored and
+// simply expire, so for them, as for unknown tokens, it just says OK.
+func (cfg *apiConfig) oauthRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Invalid form body"})
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, http.StatusUnauthorized, &oauthError{"invalid_client", "Client authentication failed"})
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "token is required"})
+		return
+	}
+
+	current, err := cfg.dbQueries.GetUserFromRefreshToken(r.Context(), token)
+	if err == nil && current.ClientID.Valid && current.ClientID.UUID == client.ID {
+		_, err = cfg.dbQueries.RevokeRefreshTokenFamily(r.Context(), current.FamilyID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		fmt.Printf("Error revoking OAuth token: %v\n", err)
+		respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{"server_error", "Failed to revoke token"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateOAuthClient identifies the client calling the token or
+// revocation endpoint, by HTTP Basic auth or client_id and client_secret
+// form fields. Public clients only give their client_id.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 form-encodes both before they are put in the header
+		var err1, err2 error
+		id, err1 = url.QueryUnescape(id)
+		secret, err2 = url.QueryUnescape(secret)
+		if err1 != nil || err2 != nil {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	client, err := cfg.dbQueries.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	if client.SecretHash.Valid {
+		given := auth.HashToken(secret)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(given), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+	}
+	return client, nil
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthErr *oauthError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(oauthErr)
+}
+
+// oauthMetadataHandler serves the RFC 8414 authorization server metadata,
+// from which clients can discover the endpoints.
+func (cfg *apiConfig) oauthMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	authMethods := []string{"client_secret_basic", "client_secret_post", "none"}
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                     cfg.baseURL,
+		"authorization_endpoint":                     cfg.baseURL + "/oauth/authorize",
+		"token_endpoint":                             cfg.baseURL + "/oauth/token",
+		"revocation_endpoint":                        cfg.baseURL + "/oauth/revoke",
+		"jwks_uri":                                   cfg.baseURL + "/.well-known/jwks.json",
+		"scopes_supported":                           auth.Scopes,
+		"response_types_supported":                   []string{"code"},
+		"grant_types_supported":                      []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":           []string{"S256"},
+		"token_endpoint_auth_methods_supported":      authMethods,
+		"revocation_endpoint_auth_methods_supported": authMethods,
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"chirpy-project/internal/mail"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	passwordResetLifetime = time.Hour
+	mailSendTimeout       = 30 * time.Second
+)
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// forgotPasswordHandler emails a password reset link. It answers the same way
+// whether or not the address has an account, and sends in the background so
+// the response time does not give that away either.
+func (cfg *apiConfig) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	params := forgotPasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Limited by address whether or not it has an account, so the limit
+	// gives nothing away either
+	if !cfg.throttleMail(w, r, params.Email) {
+		return
+	}
+
+	go cfg.sendPasswordReset(params.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+
+	user, err := cfg.dbQueries.Login(ctx, email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			fmt.Printf("Error looking up user for password reset: %v\n", err)
+		}
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		fmt.Printf("Error generating password reset token: %v\n", err)
+		return
+	}
+	err = cfg.dbQueries.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	})
+	if err != nil {
+		fmt.Printf("Error storing password reset token: %v\n", err)
+		return
+	}
+
+	link := cfg.baseURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: "Someone asked to reset the password for your Chirpy account.\n\n" +
+			"To choose a new password, open this link within the next hour:\n\n" +
+			link + "\n\n" +
+			"If it wasn't you, you can ignore this email; your password has not changed.\n",
+	})
+	if err != nil {
+		fmt.Printf("Error sending password reset email: %v\n", err)
+	}
+}
+
+// resetPasswordHandler sets a new password with a token from the reset email.
+// Every session is logged out, since whoever held the old password may have
+// one.
+func (cfg *apiConfig) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	params := resetPasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if params.Token == "" || params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Token and password are required")
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to hash password")
+		return
+	}
+
+	err = cfg.resetPassword(r.Context(), auth.HashToken(params.Token), hashedPassword)
+	if errors.Is(err, errInvalidResetToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error resetting password: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetPassword uses up the reset token and replaces the password in one
+// transaction, so a failure leaves the token usable.
+func (cfg *apiConfig) resetPassword(ctx context.Context, tokenHash, hashedPassword string) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	reset, err := qtx.UsePasswordResetToken(ctx, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if reset.ExpiresAt.Before(time.Now().UTC()) {
+		return errInvalidResetToken
+	}
+
+	err = qtx.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID:             reset.UserID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return err
+	}
+	// Any other reset links still in someone's inbox stop working too
+	if err := qtx.InvalidatePasswordResetTokens(ctx, reset.UserID); err != nil {
+		return err
+	}
+	if _, err := qtx.RevokeAllUserSessions(ctx, reset.UserID); err != nil {
+		return err
+	}
+	if err := logSecurityEvent(ctx, qtx, reset.UserID, securityEventPasswordReset, "password reset by email"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// polkaWebhookTolerance is how far a webhook's timestamp may be from our
+	// clock. Older deliveries are refused, so a replay has to come within
+	// twice this of the original.
+	polkaWebhookTolerance = 5 * time.Minute
+	// webhookEventRetention is how long event IDs are remembered. Retries are
+	// signed afresh, so it is Polka's retry schedule rather than the
+	// tolerance that decides how long a duplicate can turn up.
+	webhookEventRetention = 7 * 24 * time.Hour
+	maxWebhookBodyBytes   = 1 << 20
+)
+
+type polkaEvent struct {
+	Event string         `json:"event"`
+	Data  polkaEventData `json:"data"`
+}
+
+// polkaWebhookHandler takes Polka's events about Chirpy Red subscriptions.
+func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Read the raw body, since that is what Polka signs
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		errorResp := errorResponse{
+			Error: "Invalid request payload",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+
+	// Check the signature and its timestamp, if not valid return 401 status code
+	eventID, err := auth.VerifyWebhook(cfg.polkaSecrets, r.Header, body, time.Now(), polkaWebhookTolerance)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		errorResp := errorResponse{
+			Error: "Unauthorized",
+		}
+		fmt.Printf("Rejected Polka webhook: %v\n", err)
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+
+	// Events other than those about subscriptions are acknowledged with a 204 status code and ignored
+	var params polkaEvent
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		errorResp := errorResponse{
+			Error: "Invalid request payload",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+
+	if !isSubscriptionEvent(params.Event) {
+		w.WriteHeader(http.StatusNoContent)
+		fmt.Printf("Ignoring Polka event %s\n", params.Event)
+		return
+	}
+
+	// Update the user's subscription, once per event
+	err = cfg.processWebhookEvent(r.Context(), eventID, params.Event, func(q *database.Queries) error {
+		return applySubscriptionEvent(r.Context(), q, params.Event, params.Data, time.Now().UTC())
+	})
+	// Check if user exists in database, if not return 404
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		errorResp := errorResponse{
+			Error: "User not found",
+		}
+		fmt.Printf("User not found: %v\n", err)
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		errorResp := errorResponse{
+			Error: "Internal Server Error",
+		}
+		fmt.Printf("Error updating subscription: %v\n", err)
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+	// Respond with 204 status code and an empty response body
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processWebhookEvent runs apply for the webhook event with id, unless that
+// event was processed before. The event is only recorded if apply succeeds,
+// so Polka's retries of a failed delivery get another go.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, id, event string, apply func(q *database.Queries) error) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// A concurrent delivery of the same event waits here until this one
+	// commits, and then finds it recorded
+	recorded, err := qtx.RecordWebhookEvent(ctx, database.RecordWebhookEventParams{ID: id, Event: event})
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		fmt.Printf("Webhook event %s already processed\n", id)
+		return nil
+	}
+
+	if err := apply(qtx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// rechirpHandler shares another chirp to the caller's followers without
+// commentary. Quotes go through createChirpHandler with quote_of instead.
+func (cfg *apiConfig) rechirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	if ok, err := cfg.checkCanChirp(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load user")
+		return
+	} else if !ok {
+		respondWithError(w, http.StatusForbidden, "Verify your email address to chirp")
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	target, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	chirp, err := cfg.insertChirp(r.Context(), database.CreateChirpParams{
+		Body:       "",
+		UserID:     userID,
+		RefChirpID: uuid.NullUUID{UUID: originalChirpID(target), Valid: true},
+		RefKind:    sql.NullString{String: refKindRechirp, Valid: true},
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, "Chirp already rechirped")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error creating rechirp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create rechirp")
+		return
+	}
+
+	resp, err := cfg.buildChirpResponse(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, resp)
+}
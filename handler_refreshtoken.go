@@ -2,93 +2,184 @@ package main
 
 import (
 	"chirpy-project/internal/auth"
-	"encoding/json"
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+var errRefreshTokenReused = errors.New("refresh token already used")
+
 type accessTokenResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
+// refreshTokenHandler trades a refresh token for a new access token and a new
+// refresh token; the one presented stops working. Presenting a refresh token
+// that was already rotated or revoked means it has leaked, so every token in
+// its family is revoked and the user has to log in again.
 func (cfg *apiConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	requesttoken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		errorResp := errorResponse{
-			Error: "Unauthorized",
-		}
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
-		return
-	}
-	if requesttoken == "" {
-		w.WriteHeader(http.StatusUnauthorized)
-		errorResp := errorResponse{
-			Error: "Unauthorized",
-		}
+	if err != nil || requesttoken == "" {
 		fmt.Printf("GetBearerToken not found: %v\n", err)
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
-	user, err := cfg.dbQueries.GetUserFromRefreshToken(r.Context(), requesttoken)
+	current, err := cfg.dbQueries.GetUserFromRefreshToken(r.Context(), requesttoken)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		errorResp := errorResponse{
-			Error: "Unauthorized",
-		}
 		fmt.Printf("User not found with refresh token: %v\n", err)
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
-
-	if user.ExpiresAt.Before(time.Now()) {
-		w.WriteHeader(http.StatusUnauthorized)
-		errorResp := errorResponse{
-			Error: "Unauthorized",
-		}
-		fmt.Printf("User refresh token has expired: %v\n", err)
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
+	// OAuth refresh tokens are only good at the token endpoint, with the
+	// client's credentials
+	if current.ClientID.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	// Check if the refresh token has been revoked
-	if user.RevokedAt.Valid {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		errorResp := errorResponse{
-			Error: "Unauthorized - token revoked",
-		}
-		fmt.Printf("User refresh token has been revoked at: %v\n", user.RevokedAt.Time)
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
+	if current.RevokedAt.Valid {
+		cfg.handleRefreshTokenReuse(r.Context(), current)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized - token revoked")
+		return
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		fmt.Printf("User refresh token has expired at: %v\n", current.ExpiresAt)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// The role goes into the new access token, so it is read afresh
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), current.UserID)
+	if err != nil {
+		fmt.Printf("Error getting user for refresh token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate access token")
+		return
+	}
+
+	refreshToken, err := cfg.rotateRefreshToken(r.Context(), current, sessionClientFromRequest(r))
+	if errors.Is(err, errRefreshTokenReused) {
+		// Another request rotated the same token first
+		cfg.handleRefreshTokenReuse(r.Context(), current)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized - token revoked")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error rotating refresh token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate refresh token")
 		return
 	}
 
 	// User refresh token is valid, generate a new access token
-	var accessToken string
-	accessToken, err = auth.MakeJWT(user.UserID, cfg.jwtSecret, time.Hour)
+	accessToken, err := cfg.jwtKeys.MakeJWT(user.ID, user.Role, time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate access token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, accessTokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	})
+}
 
+// rotateRefreshToken revokes current and issues its replacement in the same
+// family. Only one caller can rotate a given token; the rest get
+// errRefreshTokenReused.
+func (cfg *apiConfig) rotateRefreshToken(ctx context.Context, current database.RefreshToken, client sessionClient) (string, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		errorResp := errorResponse{
-			Error: "Failed to generate access token",
-		}
-		jsonResp, _ := json.Marshal(errorResp)
-		w.Write(jsonResp)
+		return "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	rotated, err := qtx.RotateRefreshToken(ctx, current.Token)
+	if err != nil {
+		return "", err
+	}
+	if rotated == 0 {
+		return "", errRefreshTokenReused
+	}
+
+	token, err := issueRefreshToken(ctx, qtx, current.UserID, current.FamilyID, client, refreshTokenGrant{
+		ClientID: current.ClientID,
+		Scopes:   current.Scopes,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// refreshTokenRotated is refresh_tokens.revoked_reason for a token that was
+// exchanged for a new one, as opposed to logged out or revoked.
+const refreshTokenRotated = "rotated"
+
+// handleRefreshTokenReuse revokes every token in the family of a token that
+// was presented after it was rotated, since only a copy of it could still be
+// in use. Tokens that were logged out or revoked are simply refused.
+// Failures are only logged; the caller is refused either way.
+func (cfg *apiConfig) handleRefreshTokenReuse(ctx context.Context, token database.RefreshToken) {
+	// Re-read it, since it may have been rotated after the caller looked
+	latest, err := cfg.dbQueries.GetUserFromRefreshToken(ctx, token.Token)
+	if err != nil {
+		fmt.Printf("Error getting refresh token: %v\n", err)
+		return
+	}
+	if latest.RevokedReason.String != refreshTokenRotated {
 		return
 	}
 
-	refreshToken := accessTokenResponse{
-		Token: accessToken,
+	revoked, err := cfg.dbQueries.RevokeRefreshTokenFamily(ctx, token.FamilyID)
+	if err != nil {
+		fmt.Printf("Error revoking refresh token family: %v\n", err)
+		return
+	}
+	detail := fmt.Sprintf("revoked refresh token reused; family %s, %d other tokens revoked", token.FamilyID, revoked)
+	if err := logSecurityEvent(ctx, cfg.dbQueries, token.UserID, securityEventRefreshTokenReuse, detail); err != nil {
+		fmt.Printf("Error logging security event: %v\n", err)
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	jsonResp, _ := json.Marshal(refreshToken)
-	w.Write(jsonResp)
+// refreshTokenGrant is what an OAuth refresh token was granted: the client
+// it was issued to and its scopes. It is empty for tokens from logging in.
+type refreshTokenGrant struct {
+	ClientID uuid.NullUUID
+	Scopes   []string
+}
+
+// issueRefreshToken stores a new refresh token for userID, used from the
+// device described by client. Logging in starts a new family; rotation
+// passes the family and grant along.
+func issueRefreshToken(ctx context.Context, q *database.Queries, userID, familyID uuid.UUID, client sessionClient, grant refreshTokenGrant) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		RevokedAt: sql.NullTime{},
+		FamilyID:  familyID,
+		UserAgent: client.UserAgent,
+		IpAddress: client.IPAddress,
+		ClientID:  grant.ClientID,
+		Scopes:    grant.Scopes,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
 }
@@ -5,6 +5,10 @@ import (
 	"net/http"
 )
 
+// resetHandler wipes every user and the hit counter. On top of needing an
+// admin, it stays limited to dev so a production database can't be wiped.
+// Admins go too; with ADMIN_EMAIL set, signing up and verifying that address
+// again makes it an admin.
 func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
 		w.WriteHeader(http.StatusForbidden)
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type setRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// setUserRoleHandler lets an admin make a user a moderator or admin, or
+// take that away again. Admins cannot change their own role, so there is
+// always an admin left to undo a mistake.
+func (cfg *apiConfig) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	admin := requestAuthFromContext(r.Context())
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if userID == admin.UserID {
+		respondWithError(w, http.StatusForbidden, "You cannot change your own role")
+		return
+	}
+
+	params := setRoleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !auth.ValidRole(params.Role) {
+		respondWithError(w, http.StatusBadRequest, "Unknown role")
+		return
+	}
+
+	current, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error getting user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update role")
+		return
+	}
+	if current.Role == params.Role {
+		respondWithJSON(w, http.StatusOK, userToResponse(current))
+		return
+	}
+
+	user, err := cfg.dbQueries.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: params.Role,
+	})
+	if err != nil {
+		fmt.Printf("Error setting user role: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update role")
+		return
+	}
+
+	detail := fmt.Sprintf("role changed from %s to %s by admin %s", current.Role, user.Role, admin.UserID)
+	if err := logSecurityEvent(r.Context(), cfg.dbQueries, user.ID, securityEventRoleChanged, detail); err != nil {
+		fmt.Printf("Error logging security event: %v\n", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, userToResponse(user))
+}
+
+// grantBootstrapAdmin makes user an admin if they own the ADMIN_EMAIL
+// address, so the first admin needs no access to the database. The address
+// has to be verified, or anyone could sign up with it first.
+func grantBootstrapAdmin(ctx context.Context, q *database.Queries, adminEmail string, user database.User) (database.User, error) {
+	if adminEmail == "" || user.Email != adminEmail || !user.EmailVerifiedAt.Valid || user.Role == auth.RoleAdmin {
+		return user, nil
+	}
+
+	promoted, err := q.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: auth.RoleAdmin,
+	})
+	if err != nil {
+		return user, err
+	}
+	detail := fmt.Sprintf("role changed from %s to %s by ADMIN_EMAIL", user.Role, promoted.Role)
+	if err := logSecurityEvent(ctx, q, promoted.ID, securityEventRoleChanged, detail); err != nil {
+		return user, err
+	}
+	return promoted, nil
+}
+
+// bootstrapAdmin promotes the ADMIN_EMAIL account at startup, for when it
+// was verified before the setting was made.
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context) error {
+	if cfg.adminEmail == "" {
+		return nil
+	}
+	user, err := cfg.dbQueries.Login(ctx, cfg.adminEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = grantBootstrapAdmin(ctx, cfg.dbQueries, cfg.adminEmail, user)
+	return err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"database/sql"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type searchResult struct {
+	Chirp chirpResponse `json:"chirp"`
+	Rank  float32       `json:"rank"`
+	// Snippet is HTML: the escaped body with matches wrapped in <mark>
+	Snippet string `json:"snippet"`
+}
+
+// ts_headline marks matches with these private-use characters, which are
+// stripped from the body first, so the body can be escaped before they are
+// turned into <mark> tags.
+const (
+	snippetMatchStart = "\ue000"
+	snippetMatchStop  = "\ue001"
+)
+
+var snippetMarkup = strings.NewReplacer(snippetMatchStart, "<mark>", snippetMatchStop, "</mark>")
+
+// snippetHTML turns a headline from the search queries into safe HTML.
+func snippetHTML(headline string) string {
+	return snippetMarkup.Replace(html.EscapeString(headline))
+}
+
+type searchPageResponse struct {
+	Results    []searchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+// searchChirpsHandler runs a full-text search over chirp bodies. The q
+// parameter uses web search syntax, so "quoted phrases", OR and -exclusions
+// all work. Results are ordered by relevance, best match first.
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		respondWithError(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	if raw := query.Get("author_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+
+	since, err := parseTimeParam(query.Get("since"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid since")
+		return
+	}
+	until, err := parseTimeParam(query.Get("until"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid until")
+		return
+	}
+
+	limit, cursor, err := parsePageParams(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters")
+		return
+	}
+
+	rows, hasMore, err := fetchPage(true, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]database.SearchChirpsDescRow, error) {
+		if desc {
+			return cfg.dbQueries.SearchChirpsDesc(r.Context(), database.SearchChirpsDescParams{
+				Query:           q,
+				AuthorID:        authorID,
+				Since:           since,
+				Until:           until,
+				CursorRank:      cursor.rankParam(),
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+		}
+		ascRows, err := cfg.dbQueries.SearchChirpsAsc(r.Context(), database.SearchChirpsAscParams{
+			Query:           q,
+			AuthorID:        authorID,
+			Since:           since,
+			Until:           until,
+			CursorRank:      cursor.rankParam(),
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
+		})
+		descRows := make([]database.SearchChirpsDescRow, 0, len(ascRows))
+		for _, row := range ascRows {
+			descRows = append(descRows, database.SearchChirpsDescRow(row))
+		}
+		return descRows, err
+	})
+	if err != nil {
+		fmt.Printf("Error searching chirps: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to search chirps")
+		return
+	}
+
+	chirpsDB := make([]database.Chirp, 0, len(rows))
+	keys := make([]pageKey, 0, len(rows))
+	for _, row := range rows {
+		chirpsDB = append(chirpsDB, row.Chirp)
+		keys = append(keys, pageKey{CreatedAt: row.Chirp.CreatedAt, ID: row.Chirp.ID, Rank: row.Rank})
+	}
+
+	chirps, err := cfg.buildChirpResponses(r.Context(), cfg.optionalRequestUserID(r), chirpsDB)
+	if err != nil {
+		fmt.Printf("Error building chirp responses: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to search chirps")
+		return
+	}
+
+	resp := searchPageResponse{Results: []searchResult{}}
+	for i, row := range rows {
+		resp.Results = append(resp.Results, searchResult{
+			Chirp:   chirps[i],
+			Rank:    row.Rank,
+			Snippet: snippetHTML(row.Snippet),
+		})
+	}
+	resp.NextCursor, resp.PrevCursor = pageInfo(keys, cursor, hasMore)
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter.
+func parseTimeParam(raw string) (sql.NullTime, error) {
+	if raw == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
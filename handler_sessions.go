@@ -0,0 +1,137 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength keeps a hostile User-Agent header from bloating
+// refresh_tokens.
+const maxUserAgentLength = 512
+
+// sessionClient describes the device a refresh token was issued to.
+type sessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// sessionClientFromRequest reads the client's user agent and address. The
+// address is the peer of the connection; forwarding headers are not trusted
+// since anyone can set them.
+func sessionClientFromRequest(r *http.Request) sessionClient {
+	// Postgres refuses invalid UTF-8, which clients are free to send, so
+	// replace it and only cut the header between characters
+	userAgent := strings.ToValidUTF8(r.UserAgent(), "\uFFFD")
+	if len(userAgent) > maxUserAgentLength {
+		cut := maxUserAgentLength
+		for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+			cut--
+		}
+		userAgent = userAgent[:cut]
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return sessionClient{UserAgent: userAgent, IPAddress: ip}
+}
+
+// sessionResponse is one logged-in device. Its ID is the refresh token
+// family, so it stays the same as the token is rotated.
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// listSessionsHandler returns the caller's live sessions, most recently used
+// first.
+func (cfg *apiConfig) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessions, err := cfg.dbQueries.ListUserSessions(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error listing sessions: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list sessions from database")
+		return
+	}
+
+	resp := []sessionResponse{}
+	for _, session := range sessions {
+		resp = append(resp, sessionResponse{
+			ID:         session.FamilyID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+			CreatedAt:  session.StartedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// revokeSessionHandler logs one of the caller's devices out. Access tokens
+// already issued to it stay valid until they expire.
+func (cfg *apiConfig) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		fmt.Printf("Error revoking session: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	// Someone else's session looks the same as one that does not exist
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessionsHandler logs the caller out everywhere, including the
+// session making the request.
+func (cfg *apiConfig) revokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if _, err := cfg.dbQueries.RevokeAllUserSessions(r.Context(), userID); err != nil {
+		fmt.Printf("Error revoking sessions: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamReplayPageSize    = 500
+)
+
+// chirpStreamEvent is the JSON body of one pushed event. Created events carry
+// the whole chirp; deleted events only identify what went away.
+type chirpStreamEvent struct {
+	Type    string         `json:"type"`
+	ChirpID uuid.UUID      `json:"chirp_id"`
+	UserID  uuid.UUID      `json:"user_id"`
+	Chirp   *chirpResponse `json:"chirp,omitempty"`
+}
+
+// chirpStreamFilter narrows a stream to one author and/or the users the
+// viewer followed when the stream was opened. The zero value matches all.
+type chirpStreamFilter struct {
+	authorID  uuid.NullUUID
+	followees map[uuid.UUID]bool
+}
+
+func (f chirpStreamFilter) matches(ev chirpEvent) bool {
+	if f.authorID.Valid && ev.UserID != f.authorID.UUID {
+		return false
+	}
+	if f.followees != nil && !f.followees[ev.UserID] {
+		return false
+	}
+	return true
+}
+
+// streamChirpsHandler pushes chirp creations and deletions as Server-Sent
+// Events. Clients that reconnect with Last-Event-ID (or last_event_id) first
+// receive everything they missed, then the live stream.
+func (cfg *apiConfig) streamChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	viewerID := cfg.optionalRequestUserID(r)
+
+	filter := chirpStreamFilter{}
+	if raw := query.Get("author_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id")
+			return
+		}
+		filter.authorID = uuid.NullUUID{UUID: parsed, Valid: true}
+	}
+	if query.Get("following") == "true" {
+		if !viewerID.Valid {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		followees, err := cfg.dbQueries.ListFolloweeIDs(r.Context(), viewerID.UUID)
+		if err != nil {
+			fmt.Printf("Error listing followees: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to load followed users")
+			return
+		}
+		filter.followees = make(map[uuid.UUID]bool, len(followees))
+		for _, id := range followees {
+			filter.followees[id] = true
+		}
+	}
+
+	lastEventID := int64(0)
+	rawLastID := r.Header.Get("Last-Event-ID")
+	if rawLastID == "" {
+		rawLastID = query.Get("last_event_id")
+	}
+	if rawLastID != "" {
+		parsed, err := strconv.ParseInt(rawLastID, 10, 64)
+		if err != nil || parsed < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		lastEventID = parsed
+	}
+
+	// Subscribe before replaying so nothing committed in between is missed
+	sub := cfg.chirpHub.subscribe()
+	defer cfg.chirpHub.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		fmt.Printf("Streaming not supported: %v\n", err)
+		return
+	}
+
+	replayed := map[int64]bool{}
+	for lastEventID > 0 {
+		events, err := cfg.dbQueries.ListChirpEventsAfter(r.Context(), database.ListChirpEventsAfterParams{
+			ID:    lastEventID,
+			Limit: streamReplayPageSize,
+		})
+		if err != nil {
+			fmt.Printf("Error replaying chirp events: %v\n", err)
+			return
+		}
+		for _, dbEv := range events {
+			ev := chirpEventFromDB(dbEv)
+			replayed[ev.ID] = true
+			lastEventID = ev.ID
+			if !filter.matches(ev) {
+				continue
+			}
+			if err := cfg.writeChirpStreamEvent(r.Context(), w, viewerID, ev); err != nil {
+				return
+			}
+		}
+		if len(events) < streamReplayPageSize {
+			break
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done:
+			// Fell too far behind or the server is shutting down; the client
+			// reconnects with Last-Event-ID and picks up where it left off.
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case ev := <-sub.Events:
+			if replayed[ev.ID] || !filter.matches(ev) {
+				continue
+			}
+			if err := cfg.writeChirpStreamEvent(r.Context(), w, viewerID, ev); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// chirpStreamPayload loads what is pushed for ev. It reports false for a
+// created chirp that has already been deleted again; its deleted event
+// follows, so there is nothing to send.
+func (cfg *apiConfig) chirpStreamPayload(ctx context.Context, viewerID uuid.NullUUID, ev chirpEvent) (chirpStreamEvent, bool, error) {
+	payload := chirpStreamEvent{
+		Type:    "chirp." + ev.Kind,
+		ChirpID: ev.ChirpID,
+		UserID:  ev.UserID,
+	}
+	if ev.Kind != chirpEventCreated {
+		return payload, true, nil
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(ctx, ev.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return payload, false, nil
+	}
+	if err != nil {
+		return payload, false, err
+	}
+	resp, err := cfg.buildChirpResponse(ctx, viewerID, chirp)
+	if err != nil {
+		return payload, false, err
+	}
+	payload.Chirp = &resp
+	return payload, true, nil
+}
+
+// writeChirpStreamEvent writes one event in SSE framing.
+func (cfg *apiConfig) writeChirpStreamEvent(ctx context.Context, w http.ResponseWriter, viewerID uuid.NullUUID, ev chirpEvent) error {
+	payload, ok, err := cfg.chirpStreamPayload(ctx, viewerID, ev)
+	if err != nil || !ok {
+		return err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, payload.Type, data)
+	return err
+}
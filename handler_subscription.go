@@ -0,0 +1,51 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type subscriptionResponse struct {
+	// Status is "none" for users who never subscribed
+	Status           string     `json:"status"`
+	Plan             string     `json:"plan,omitempty"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+	IsChirpyRed      bool       `json:"is_chirpy_red"`
+}
+
+// getSubscriptionHandler shows the caller the state of their Chirpy Red
+// subscription.
+func (cfg *apiConfig) getSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	sub, err := cfg.dbQueries.GetSubscription(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithJSON(w, http.StatusOK, subscriptionResponse{Status: "none"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error getting subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to get subscription")
+		return
+	}
+
+	status := subscriptionStatus(sub, time.Now().UTC())
+	resp := subscriptionResponse{
+		Status:      status,
+		Plan:        sub.Plan,
+		IsChirpyRed: status == subscriptionActive || status == subscriptionPastDue,
+	}
+	// A period Polka hasn't confirmed is only a guess, so it isn't shown
+	if sub.PeriodConfirmed {
+		resp.CurrentPeriodEnd = &sub.CurrentPeriodEnd
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
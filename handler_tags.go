@@ -0,0 +1,139 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"chirpy-project/internal/entities"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTrendingWindow   = time.Hour
+	defaultTrendingBaseline = 24 * time.Hour
+	defaultTrendingLimit    = 10
+	maxTrendingLimit        = 50
+)
+
+type trendingTagResponse struct {
+	Tag           string  `json:"tag"`
+	RecentCount   int64   `json:"recent_count"`
+	BaselineCount int64   `json:"baseline_count"`
+	Score         float64 `json:"score"`
+}
+
+// tagChirpsHandler lists chirps carrying a hashtag, newest first.
+func (cfg *apiConfig) tagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" || len(tag) > entities.MaxTagLength {
+		respondWithError(w, http.StatusBadRequest, "Invalid tag")
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters")
+		return
+	}
+
+	chirpsDB, hasMore, err := fetchPage(true, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]database.Chirp, error) {
+		if desc {
+			return cfg.dbQueries.ListTagChirpsDesc(r.Context(), database.ListTagChirpsDescParams{
+				Tag:             tag,
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+		}
+		return cfg.dbQueries.ListTagChirpsAsc(r.Context(), database.ListTagChirpsAscParams{
+			Tag:             tag,
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
+		})
+	})
+	if err != nil {
+		fmt.Printf("Error listing tag chirps: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
+	}
+
+	chirps, err := cfg.buildChirpResponses(r.Context(), cfg.optionalRequestUserID(r), chirpsDB)
+	if err != nil {
+		fmt.Printf("Error building chirp responses: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
+	}
+
+	resp := chirpPageResponse{Chirps: chirps}
+	keys := make([]pageKey, 0, len(chirpsDB))
+	for _, chirpDB := range chirpsDB {
+		keys = append(keys, pageKey{CreatedAt: chirpDB.CreatedAt, ID: chirpDB.ID})
+	}
+	resp.NextCursor, resp.PrevCursor = pageInfo(keys, cursor, hasMore)
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// trendingTagsHandler ranks tags by how much more they are used in the recent
+// window than their baseline rate over the longer period before it. Both
+// periods can be tuned with the `window` and `baseline` duration parameters.
+func (cfg *apiConfig) trendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	window, err := parseDurationParam(query.Get("window"), defaultTrendingWindow)
+	if err != nil || window <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid window")
+		return
+	}
+	baseline, err := parseDurationParam(query.Get("baseline"), defaultTrendingBaseline)
+	if err != nil || baseline <= window {
+		respondWithError(w, http.StatusBadRequest, "Invalid baseline")
+		return
+	}
+
+	limit := defaultTrendingLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = min(parsed, maxTrendingLimit)
+	}
+
+	now := time.Now().UTC()
+	rows, err := cfg.dbQueries.ListTrendingTags(r.Context(), database.ListTrendingTagsParams{
+		WindowStart: now.Add(-window),
+		// Scales the baseline count down to the expected count for one window
+		WindowFraction: float64(window) / float64(baseline-window),
+		BaselineStart:  now.Add(-baseline),
+		RowLimit:       int32(limit),
+	})
+	if err != nil {
+		fmt.Printf("Error listing trending tags: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list trending tags")
+		return
+	}
+
+	resp := []trendingTagResponse{}
+	for _, row := range rows {
+		resp = append(resp, trendingTagResponse{
+			Tag:           row.Tag,
+			RecentCount:   row.RecentCount,
+			BaselineCount: row.BaselineCount,
+			Score:         row.Score,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// parseDurationParam parses an optional Go duration such as "1h" or "30m".
+func parseDurationParam(raw string, fallback time.Duration) (time.Duration, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(raw)
+}
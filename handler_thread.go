@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type threadNode struct {
+	chirpResponse
+	ReplyCount int           `json:"reply_count"`
+	Replies    []*threadNode `json:"replies"`
+	// Deleted marks the placeholder for a root chirp that was deleted
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// threadHandler returns the whole conversation a chirp belongs to as a tree
+// rooted at the chirp that started it.
+func (cfg *apiConfig) threadHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	rootID := chirp.ID
+	if chirp.RootID.Valid {
+		rootID = chirp.RootID.UUID
+	}
+
+	chirpsDB, err := cfg.dbQueries.ListThreadChirps(r.Context(), rootID)
+	if err != nil {
+		fmt.Printf("Error listing thread: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
+	}
+
+	chirps, err := cfg.buildChirpResponses(r.Context(), cfg.optionalRequestUserID(r), chirpsDB)
+	if err != nil {
+		fmt.Printf("Error building chirp responses: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
+	}
+
+	// Rows come back oldest first, so a parent is always seen before its
+	// replies. The root may have been deleted, in which case its replies hang
+	// off a placeholder for it.
+	root := &threadNode{
+		chirpResponse: chirpResponse{ID: rootID, Entities: chirpEntities{Mentions: []mentionEntity{}}},
+		Replies:       []*threadNode{},
+		Deleted:       true,
+	}
+	nodes := make(map[uuid.UUID]*threadNode, len(chirpsDB))
+	nodes[rootID] = root
+	for i, chirpDB := range chirpsDB {
+		if chirpDB.ID == rootID {
+			root.chirpResponse = chirps[i]
+			root.Deleted = false
+			continue
+		}
+		node := &threadNode{chirpResponse: chirps[i], Replies: []*threadNode{}}
+		nodes[chirpDB.ID] = node
+		if parent, ok := nodes[chirpDB.ParentID.UUID]; ok && chirpDB.ParentID.Valid {
+			parent.Replies = append(parent.Replies, node)
+			parent.ReplyCount++
+			continue
+		}
+		// The parent was deleted; hang the reply off the root so it stays visible
+		root.Replies = append(root.Replies, node)
+		root.ReplyCount++
+	}
+
+	if root.Deleted && len(root.Replies) == 0 {
+		respondWithError(w, http.StatusNotFound, "Thread not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, root)
+}
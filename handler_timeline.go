@@ -0,0 +1,63 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// timelineHandler returns chirps from everyone the caller follows, newest first.
+func (cfg *apiConfig) timelineHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, cursor, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters")
+		return
+	}
+
+	chirpsDB, hasMore, err := fetchPage(true, limit, cursor, func(desc bool, cursor *pageCursor, rowLimit int32) ([]database.Chirp, error) {
+		if desc {
+			return cfg.dbQueries.ListTimelineChirpsDesc(r.Context(), database.ListTimelineChirpsDescParams{
+				FollowerID:      userID,
+				CursorCreatedAt: cursor.createdAtParam(),
+				CursorID:        cursor.idParam(),
+				RowLimit:        rowLimit,
+			})
+		}
+		return cfg.dbQueries.ListTimelineChirpsAsc(r.Context(), database.ListTimelineChirpsAscParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursor.createdAtParam(),
+			CursorID:        cursor.idParam(),
+			RowLimit:        rowLimit,
+		})
+	})
+	if err != nil {
+		fmt.Printf("Error listing timeline: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
+	}
+
+	chirps, err := cfg.buildChirpResponses(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirpsDB)
+	if err != nil {
+		fmt.Printf("Error building chirp responses: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list chirps from database")
+		return
+	}
+
+	resp := chirpPageResponse{Chirps: chirps}
+	keys := make([]pageKey, 0, len(chirpsDB))
+	for _, chirpDB := range chirpsDB {
+		keys = append(keys, pageKey{CreatedAt: chirpDB.CreatedAt, ID: chirpDB.ID})
+	}
+	resp.NextCursor, resp.PrevCursor = pageInfo(keys, cursor, hasMore)
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxTokenNameLength keeps token names to something a list can show.
+const maxTokenNameLength = 100
+
+type createTokenRequest struct {
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+	Expires int      `json:"expires_in_seconds"`
+}
+
+// personalAccessTokenResponse describes a token. Token is only filled in
+// when it is created, since only its hash is kept after that.
+type personalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func personalAccessTokenToResponse(pat database.PersonalAccessToken) personalAccessTokenResponse {
+	resp := personalAccessTokenResponse{
+		ID:        pat.ID,
+		Name:      pat.Name,
+		Scopes:    pat.Scopes,
+		CreatedAt: pat.CreatedAt,
+	}
+	if pat.ExpiresAt.Valid {
+		resp.ExpiresAt = &pat.ExpiresAt.Time
+	}
+	if pat.LastUsedAt.Valid {
+		resp.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return resp
+}
+
+// createTokenHandler mints a personal access token for scripts and bots.
+// It needs a login session: one token cannot be used to mint another.
+func (cfg *apiConfig) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := createTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > maxTokenNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Token name must be 1 to %d characters", maxTokenNameLength))
+		return
+	}
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scopes: "+err.Error())
+		return
+	}
+	if params.Expires < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds must not be negative")
+		return
+	}
+	// Tokens without an expiry live until they are revoked
+	expiresAt := sql.NullTime{}
+	if params.Expires > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().Add(time.Duration(params.Expires) * time.Second), Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	pat, err := cfg.dbQueries.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		fmt.Printf("Error creating personal access token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	resp := personalAccessTokenToResponse(pat)
+	resp.Token = token
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// listTokensHandler returns the caller's unrevoked personal access tokens,
+// newest first.
+func (cfg *apiConfig) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pats, err := cfg.dbQueries.ListPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		fmt.Printf("Error listing personal access tokens: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list tokens")
+		return
+	}
+
+	resp := []personalAccessTokenResponse{}
+	for _, pat := range pats {
+		resp = append(resp, personalAccessTokenToResponse(pat))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// revokeTokenHandler revokes one of the caller's personal access tokens.
+func (cfg *apiConfig) revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.requestUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		fmt.Printf("Error revoking personal access token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Token not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"chirpy-project/internal/entities"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type updateChirpRequest struct {
+	Body string `json:"body"`
+}
+
+type chirpRevisionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+var (
+	errChirpForbidden     = errors.New("chirp does not belong to user")
+	errRechirpNotEditable = errors.New("rechirps cannot be edited")
+)
+
+func (cfg *apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the access token from header and check it
+	userID, err := cfg.authorizeRequest(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		fmt.Printf("Error authenticating chirp update: %v\n", err)
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// Parse the chirp ID from the path
+	chirpID, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	params := updateChirpRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Re-run the same validation and profanity filter as a new chirp
+	cleanedBody, err := cleanChirpBody(params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if cfg.editRequiresRed {
+		user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !user.IsChirpyRed {
+			respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red")
+			return
+		}
+	}
+
+	chirp, err := cfg.editChirp(r.Context(), userID, chirpID, cleanedBody)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+	if errors.Is(err, errChirpForbidden) {
+		respondWithError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+	if errors.Is(err, errRechirpNotEditable) {
+		respondWithError(w, http.StatusBadRequest, "Rechirps cannot be edited")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error updating chirp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update chirp")
+		return
+	}
+
+	resp, err := cfg.buildChirpResponse(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// editChirp replaces a chirp's body and records the old body as a revision.
+// The chirp row is locked for the duration so concurrent edits cannot lose a
+// revision between them.
+func (cfg *apiConfig) editChirp(ctx context.Context, userID, chirpID uuid.UUID, body string) (database.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	current, err := qtx.GetChirpForUpdate(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if current.UserID != userID {
+		return database.Chirp{}, errChirpForbidden
+	}
+	if current.RefKind.String == refKindRechirp {
+		return database.Chirp{}, errRechirpNotEditable
+	}
+
+	// Remember who was already mentioned so only new mentions notify
+	previouslyMentioned, err := qtx.ListMentionedUserIDs(ctx, current.ID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = qtx.CreateChirpRevision(ctx, database.CreateChirpRevisionParams{
+		ChirpID:   current.ID,
+		Body:      current.Body,
+		CreatedAt: current.UpdatedAt,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	updated, err := qtx.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{
+		ID:   current.ID,
+		Body: body,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	// Hashtags and mentions follow the current body, so re-extract them.
+	// Tags the edit kept stay as first recorded, so editing an old chirp
+	// doesn't count as new use of its tags in trending.
+	err = qtx.DeleteChirpTagsExcept(ctx, database.DeleteChirpTagsExceptParams{
+		ChirpID: updated.ID,
+		Keep:    entities.ExtractHashtags(updated.Body),
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if err := storeChirpTags(ctx, qtx, updated); err != nil {
+		return database.Chirp{}, err
+	}
+
+	if err := qtx.DeleteChirpMentions(ctx, updated.ID); err != nil {
+		return database.Chirp{}, err
+	}
+	mentioned, err := storeChirpMentions(ctx, qtx, updated)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	// The author of the chirp replied to was already told about it
+	if updated.ParentID.Valid {
+		parent, err := qtx.GetChirp(ctx, updated.ParentID.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		previouslyMentioned = append(previouslyMentioned, parent.UserID)
+	}
+	for _, userID := range mentioned {
+		if slices.Contains(previouslyMentioned, userID) {
+			continue
+		}
+		if err := notify(ctx, qtx, userID, updated.UserID, notificationKindMention, updated.ID); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	return updated, tx.Commit()
+}
+
+func (cfg *apiConfig) listChirpRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpid"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetChirp(r.Context(), chirpID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	revisions, err := cfg.dbQueries.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		fmt.Printf("Error listing chirp revisions: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list revisions from database")
+		return
+	}
+
+	resp := []chirpRevisionResponse{}
+	for _, revision := range revisions {
+		resp = append(resp, chirpRevisionResponse{
+			ID:         revision.ID,
+			ChirpID:    revision.ChirpID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -3,15 +3,20 @@ package main
 import (
 	"chirpy-project/internal/auth"
 	"chirpy-project/internal/database"
+	"chirpy-project/internal/entities"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
 )
 
 type updateUserRequest struct {
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Password string  `json:"password"`
+	Email    string  `json:"email"`
+	Handle   *string `json:"handle"`
 }
 
 type updateUserResponse struct {
@@ -20,6 +25,10 @@ type updateUserResponse struct {
 	UpdatedAt   string    `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Handle      string    `json:"handle,omitempty"`
+	// A new email only replaces Email once it has been verified
+	EmailVerified bool   `json:"email_verified"`
+	PendingEmail  string `json:"pending_email,omitempty"`
 }
 
 func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -43,7 +52,11 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validate the access token and get the user ID
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	ra, err := cfg.authenticateToken(r.Context(), token)
+	if err == nil && !ra.can(auth.ScopeProfileWrite) {
+		respondWithAuthError(w, missingScopeError{scope: auth.ScopeProfileWrite})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		errorResp := errorResponse{
@@ -68,24 +81,68 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Hash the new password
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	// Tokens limited to scopes can edit the profile but not take the account
+	// over, so credentials can only be changed from a login session
+	if !ra.isLoginSession() && (params.Password != "" || params.Email != "") {
+		w.WriteHeader(http.StatusForbidden)
 		errorResp := errorResponse{
-			Error: "Failed to hash password",
+			Error: "Changing email or password requires logging in",
 		}
 		jsonResp, _ := json.Marshal(errorResp)
 		w.Write(jsonResp)
 		return
 	}
 
+	// A handle is only changed when one is sent; an empty string clears it
+	if params.Handle != nil && *params.Handle != "" && !entities.ValidHandle(*params.Handle) {
+		w.WriteHeader(http.StatusBadRequest)
+		errorResp := errorResponse{
+			Error: "Invalid handle",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+
+	// Hash the new password; without one the current password is kept
+	hashedPassword := ""
+	if params.Password != "" {
+		hashedPassword, err = cfg.passwordHasher.Hash(params.Password)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			errorResp := errorResponse{
+				Error: "Failed to hash password",
+			}
+			jsonResp, _ := json.Marshal(errorResp)
+			w.Write(jsonResp)
+			return
+		}
+	}
+
 	// Update the user in the database
-	updatedUser, err := cfg.dbQueries.UpdateUser(r.Context(), database.UpdateUserParams{
-		ID:             userID,
+	updatedUser, verifyEmail, err := cfg.saveUserUpdate(r.Context(), database.UpdateUserParams{
+		ID:             ra.UserID,
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
-	})
+	}, params.Handle)
+	if errors.Is(err, errEmailTaken) {
+		w.WriteHeader(http.StatusConflict)
+		errorResp := errorResponse{
+			Error: "Email already in use",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
+	if isHandleConflict(err) {
+		w.WriteHeader(http.StatusConflict)
+		errorResp := errorResponse{
+			Error: "Handle already taken",
+		}
+		jsonResp, _ := json.Marshal(errorResp)
+		w.Write(jsonResp)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		errorResp := errorResponse{
@@ -96,16 +153,87 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if verifyEmail {
+		go cfg.sendEmailVerification(updatedUser.ID, updatedUser.PendingEmail.String)
+	}
+
 	// Respond with the updated user information (excluding password)
 	response := updateUserResponse{
-		ID:          updatedUser.ID,
-		CreatedAt:   updatedUser.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt:   updatedUser.UpdatedAt.Format("2006-01-02T15:04:05Z"),
-		Email:       updatedUser.Email,
-		IsChirpyRed: updatedUser.IsChirpyRed,
+		ID:            updatedUser.ID,
+		CreatedAt:     updatedUser.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:     updatedUser.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		Email:         updatedUser.Email,
+		IsChirpyRed:   updatedUser.IsChirpyRed,
+		Handle:        updatedUser.Handle.String,
+		EmailVerified: updatedUser.EmailVerifiedAt.Valid,
+		PendingEmail:  updatedUser.PendingEmail.String,
 	}
 
 	w.WriteHeader(http.StatusOK)
 	jsonResp, _ := json.Marshal(response)
 	w.Write(jsonResp)
 }
+
+// saveUserUpdate applies the password update, if any, and when handle is
+// not nil the handle change, in one transaction. A different email is not
+// applied but held as pending; verifyEmail reports that it needs a
+// verification email.
+func (cfg *apiConfig) saveUserUpdate(ctx context.Context, params database.UpdateUserParams, handle *string) (user database.User, verifyEmail bool, err error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	current, err := qtx.GetUserByID(ctx, params.ID)
+	if err != nil {
+		return database.User{}, false, err
+	}
+	newEmail := params.Email
+	params.Email = current.Email
+	if params.HashedPassword == "" {
+		params.HashedPassword = current.HashedPassword
+	}
+
+	user, err = qtx.UpdateUser(ctx, params)
+	if err != nil {
+		return database.User{}, false, err
+	}
+
+	// Asking for the current email again drops any pending change
+	if newEmail == "" || newEmail == current.Email {
+		if current.PendingEmail.Valid {
+			user, err = qtx.SetPendingEmail(ctx, database.SetPendingEmailParams{ID: params.ID})
+			if err != nil {
+				return database.User{}, false, err
+			}
+		}
+	} else if newEmail != current.PendingEmail.String {
+		if _, err := qtx.Login(ctx, newEmail); err == nil {
+			return database.User{}, false, errEmailTaken
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return database.User{}, false, err
+		}
+		user, err = qtx.SetPendingEmail(ctx, database.SetPendingEmailParams{
+			ID:           params.ID,
+			PendingEmail: sql.NullString{String: newEmail, Valid: true},
+		})
+		if err != nil {
+			return database.User{}, false, err
+		}
+		verifyEmail = true
+	}
+
+	if handle != nil {
+		user, err = qtx.SetUserHandle(ctx, database.SetUserHandleParams{
+			ID:     params.ID,
+			Handle: sql.NullString{String: *handle, Valid: *handle != ""},
+		})
+		if err != nil {
+			return database.User{}, false, err
+		}
+	}
+
+	return user, verifyEmail, tx.Commit()
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/websocket"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// The server pings every wsPingInterval and gives up on a client that has
+	// sent nothing, not even a pong, for wsPongWait.
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	// wsWriteWait bounds every write, so a client that stops reading is
+	// disconnected instead of blocking its connection forever.
+	wsWriteWait      = 10 * time.Second
+	wsMaxMessageSize = 4096
+	wsMaxChannels    = 50
+)
+
+const wsNotificationsChannel = "notifications"
+
+var errUnknownChannel = errors.New("unknown channel")
+
+// wsClientMessage is what clients send: subscribe/unsubscribe with a channel,
+// or an application-level ping for clients that cannot send ping frames.
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+}
+
+type wsServerMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// wsChannel is a subscribed channel. match picks the chirp events it
+// receives; it is nil for the notifications channel.
+type wsChannel struct {
+	name  string
+	match func(ev chirpEvent) bool
+}
+
+type wsSession struct {
+	cfg      *apiConfig
+	conn     *websocket.Conn
+	userID   uuid.UUID
+	auth     requestAuth
+	channels map[string]wsChannel
+}
+
+// websocketHandler upgrades to a WebSocket on which the caller can subscribe
+// to live channels: "timeline", "user:{id}", "chirp:{id}" (the whole thread
+// the chirp belongs to) and "notifications". Browsers cannot set headers on
+// a WebSocket, so the token may also be passed as the access_token parameter.
+// Personal access tokens need chirps:read, and notifications:read for the
+// notifications channel.
+func (cfg *apiConfig) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	ra, err := cfg.authenticateToken(r.Context(), token)
+	if err == nil && !ra.can(auth.ScopeChirpsRead) {
+		err = missingScopeError{scope: auth.ScopeChirpsRead}
+	}
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		fmt.Printf("Error upgrading to websocket: %v\n", err)
+		return
+	}
+	cfg.wsConns.Add(1)
+	defer cfg.wsConns.Done()
+	defer conn.Close()
+
+	session := &wsSession{
+		cfg:      cfg,
+		conn:     conn,
+		userID:   ra.UserID,
+		auth:     ra,
+		channels: map[string]wsChannel{},
+	}
+	session.run()
+}
+
+func (s *wsSession) run() {
+	// The request context is not tied to a hijacked connection
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chirpSub := s.cfg.chirpHub.subscribe()
+	defer s.cfg.chirpHub.unsubscribe(chirpSub)
+	notificationSub := s.cfg.chirpHub.subscribeNotifications(s.userID)
+	defer s.cfg.chirpHub.unsubscribeNotifications(s.userID, notificationSub)
+
+	incoming := s.readLoop(ctx)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case msg, ok := <-incoming:
+			if !ok {
+				return
+			}
+			err = s.handleMessage(ctx, msg)
+		case <-ping.C:
+			s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err = s.conn.WriteMessage(websocket.PingMessage, nil)
+		case <-chirpSub.Done:
+			s.closeForHub()
+			return
+		case <-notificationSub.Done:
+			s.closeForHub()
+			return
+		case ev := <-chirpSub.Events:
+			err = s.deliverChirpEvent(ctx, ev)
+		case ev := <-notificationSub.Events:
+			err = s.deliverNotification(ctx, ev)
+		}
+		if err != nil {
+			fmt.Printf("Error writing to websocket: %v\n", err)
+			return
+		}
+	}
+}
+
+// readLoop decodes client messages on its own goroutine. The returned channel
+// is closed once the client goes away or breaks the protocol.
+func (s *wsSession) readLoop(ctx context.Context) <-chan wsClientMessage {
+	incoming := make(chan wsClientMessage)
+
+	s.conn.SetReadLimit(wsMaxMessageSize)
+	s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	s.conn.SetPongHandler(func() {
+		s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		defer close(incoming)
+		for {
+			_, data, err := s.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+			msg := wsClientMessage{}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				msg = wsClientMessage{Type: "invalid"}
+			}
+			select {
+			case incoming <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return incoming
+}
+
+// closeForHub ends the session after the hub dropped it: either the server
+// is shutting down or the client fell too far behind to keep up.
+func (s *wsSession) closeForHub() {
+	if s.cfg.chirpHub.isClosed() {
+		s.conn.WriteClose(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	s.conn.WriteClose(websocket.CloseTryAgainLater, "too slow")
+}
+
+func (s *wsSession) handleMessage(ctx context.Context, msg wsClientMessage) error {
+	switch msg.Type {
+	case "ping":
+		return s.send(wsServerMessage{Type: "pong"})
+	case "subscribe":
+		if _, ok := s.channels[msg.Channel]; ok {
+			return s.send(wsServerMessage{Type: "subscribed", Channel: msg.Channel})
+		}
+		if len(s.channels) >= wsMaxChannels {
+			return s.send(wsServerMessage{Type: "error", Channel: msg.Channel, Error: "Too many channels"})
+		}
+		channel, err := s.resolveChannel(ctx, msg.Channel)
+		if err != nil {
+			return s.send(wsServerMessage{Type: "error", Channel: msg.Channel, Error: channelErrorMessage(err)})
+		}
+		s.channels[channel.name] = channel
+		return s.send(wsServerMessage{Type: "subscribed", Channel: channel.name})
+	case "unsubscribe":
+		delete(s.channels, msg.Channel)
+		return s.send(wsServerMessage{Type: "unsubscribed", Channel: msg.Channel})
+	default:
+		return s.send(wsServerMessage{Type: "error", Error: "Invalid message"})
+	}
+}
+
+func channelErrorMessage(err error) string {
+	var scopeErr missingScopeError
+	switch {
+	case errors.Is(err, errUnknownChannel):
+		return "Unknown channel"
+	case errors.Is(err, sql.ErrNoRows):
+		return "Chirp not found"
+	case errors.As(err, &scopeErr):
+		return "Token is missing the " + scopeErr.scope + " scope"
+	default:
+		fmt.Printf("Error subscribing to channel: %v\n", err)
+		return "Failed to subscribe"
+	}
+}
+
+// resolveChannel parses a channel name into what it matches. The timeline
+// follows whoever the user followed at the time of subscribing.
+func (s *wsSession) resolveChannel(ctx context.Context, name string) (wsChannel, error) {
+	switch {
+	case name == wsNotificationsChannel:
+		if !s.auth.can(auth.ScopeNotificationsRead) {
+			return wsChannel{}, missingScopeError{scope: auth.ScopeNotificationsRead}
+		}
+		return wsChannel{name: name}, nil
+	case name == "timeline":
+		followees, err := s.cfg.dbQueries.ListFolloweeIDs(ctx, s.userID)
+		if err != nil {
+			return wsChannel{}, err
+		}
+		filter := chirpStreamFilter{followees: map[uuid.UUID]bool{}}
+		for _, id := range followees {
+			filter.followees[id] = true
+		}
+		return wsChannel{name: name, match: filter.matches}, nil
+	case strings.HasPrefix(name, "user:"):
+		authorID, err := uuid.Parse(strings.TrimPrefix(name, "user:"))
+		if err != nil {
+			return wsChannel{}, errUnknownChannel
+		}
+		filter := chirpStreamFilter{authorID: uuid.NullUUID{UUID: authorID, Valid: true}}
+		return wsChannel{name: "user:" + authorID.String(), match: filter.matches}, nil
+	case strings.HasPrefix(name, "chirp:"):
+		chirpID, err := uuid.Parse(strings.TrimPrefix(name, "chirp:"))
+		if err != nil {
+			return wsChannel{}, errUnknownChannel
+		}
+		chirp, err := s.cfg.dbQueries.GetChirp(ctx, chirpID)
+		if err != nil {
+			return wsChannel{}, err
+		}
+		rootID := chirp.ID
+		if chirp.RootID.Valid {
+			rootID = chirp.RootID.UUID
+		}
+		return wsChannel{
+			name: "chirp:" + chirpID.String(),
+			match: func(ev chirpEvent) bool {
+				return ev.ChirpID == rootID || (ev.RootID.Valid && ev.RootID.UUID == rootID)
+			},
+		}, nil
+	default:
+		return wsChannel{}, errUnknownChannel
+	}
+}
+
+func (s *wsSession) deliverChirpEvent(ctx context.Context, ev chirpEvent) error {
+	var payload *chirpStreamEvent
+	for _, channel := range s.channels {
+		if channel.match == nil || !channel.match(ev) {
+			continue
+		}
+		// Only load the chirp once, and only if someone wants it
+		if payload == nil {
+			loaded, ok, err := s.cfg.chirpStreamPayload(ctx, uuid.NullUUID{UUID: s.userID, Valid: true}, ev)
+			if err != nil {
+				fmt.Printf("Error loading chirp event: %v\n", err)
+				return nil
+			}
+			if !ok {
+				return nil
+			}
+			payload = &loaded
+		}
+		err := s.send(wsServerMessage{
+			Type:    "event",
+			Channel: channel.name,
+			Event:   payload.Type,
+			Data:    payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *wsSession) deliverNotification(ctx context.Context, ev notificationEvent) error {
+	if _, ok := s.channels[wsNotificationsChannel]; !ok {
+		return nil
+	}
+	notification, err := s.cfg.dbQueries.GetNotification(ctx, ev.ID)
+	if err != nil {
+		fmt.Printf("Error loading notification: %v\n", err)
+		return nil
+	}
+	return s.send(wsServerMessage{
+		Type:    "event",
+		Channel: wsNotificationsChannel,
+		Event:   "notification.created",
+		Data:    notificationToResponse(notification),
+	})
+}
+
+func (s *wsSession) send(msg wsServerMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
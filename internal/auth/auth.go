@@ -2,7 +2,9 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,73 +12,129 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims(userID, expiresIn))
+
+	signedToken, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
 		return "", err
 	}
-	return string(hashedPassword), err
-}
-
-func CheckPasswordHash(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err
+	return signedToken, nil
 
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+func accessTokenClaims(userID uuid.UUID, expiresIn time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		Subject:   userID.String(),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
-	})
+	}
+}
 
-	signedToken, err := token.SignedString([]byte(tokenSecret))
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return loginUserID(parseAccessToken(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
+		}
+		return []byte(tokenSecret), nil
+	}))
+}
+
+// AccessToken is what a valid access token says about its bearer.
+type AccessToken struct {
+	UserID uuid.UUID
+	// Role is the user's role when the token was issued. Scoped tokens and
+	// tokens from before roles existed are always RoleUser.
+	Role string
+	// ClientID and Scopes are set on tokens issued to an OAuth client, which
+	// may only do what the scopes allow. Tokens from logging in have neither.
+	ClientID string
+	Scopes   []string
+}
+
+// loginTokenClaims are the claims of a token from logging in.
+type loginTokenClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+}
+
+// scopedTokenClaims are the claims of a token issued to an OAuth client,
+// named as in RFC 9068.
+type scopedTokenClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+var errScopedToken = errors.New("token is limited to the scopes of an OAuth client")
+
+// loginUserID narrows a parsed token down to the user of a login session,
+// refusing tokens issued to OAuth clients.
+func loginUserID(token AccessToken, err error) (uuid.UUID, error) {
 	if err != nil {
-		return "", err
+		return uuid.Nil, err
 	}
-	return signedToken, nil
-
+	if token.Scopes != nil {
+		return uuid.Nil, errScopedToken
+	}
+	return token.UserID, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// parseAccessToken verifies a token with the key keyFunc picks for it and
+// returns the user ID in its subject, along with the role of a login token or
+// the client and scopes of a scoped token.
+func parseAccessToken(tokenString string, keyFunc jwt.Keyfunc) (AccessToken, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		jwt.MapClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
-			}
-			return []byte(tokenSecret), nil
-		},
+		keyFunc,
 		jwt.WithIssuer("chirpy"),
 	)
 
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return uuid.Nil, fmt.Errorf("invalid token or claims")
+		return AccessToken{}, fmt.Errorf("invalid token or claims")
 	}
 
 	subject, ok := claims["sub"].(string)
 	if !ok {
-		return uuid.Nil, fmt.Errorf("invalid subject in token")
+		return AccessToken{}, fmt.Errorf("invalid subject in token")
 	}
 
 	userID, err := uuid.Parse(subject)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID in token: %w", err)
+		return AccessToken{}, fmt.Errorf("invalid user ID in token: %w", err)
+	}
+
+	result := AccessToken{UserID: userID, Role: RoleUser}
+	if role, present := claims["role"]; present {
+		result.Role, _ = role.(string)
+		if !ValidRole(result.Role) {
+			return AccessToken{}, fmt.Errorf("invalid role in token")
+		}
+	}
+	if scope, present := claims["scope"]; present {
+		scopeString, _ := scope.(string)
+		clientID, _ := claims["client_id"].(string)
+		result.Scopes = strings.Fields(scopeString)
+		result.ClientID = clientID
+		if len(result.Scopes) == 0 || clientID == "" {
+			return AccessToken{}, fmt.Errorf("invalid scope in token")
+		}
+		// Roles only ever come with a full login session
+		result.Role = RoleUser
 	}
 
-	return userID, nil
+	return result, nil
 }
+
 func GetBearerToken(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
@@ -96,6 +154,14 @@ func MakeRefreshToken() (string, error) {
 	return newrefreshtoken, nil
 }
 
+// HashToken returns the form a random bearer secret is stored in. Such
+// secrets are long enough that a fast hash is sufficient, which lets them be
+// looked up directly.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
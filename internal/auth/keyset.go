@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// KeySet signs access tokens with one private key and verifies them against
+// every public key it knows, so a new signing key can be rolled out while
+// tokens signed with the previous one are still in circulation. Key IDs are
+// RFC 7638 thumbprints and are sent in the token's kid header.
+//
+// A KeySet without a signing key falls back to HS256 with the shared secret,
+// which is also accepted for verification of tokens without a kid.
+type KeySet struct {
+	signingKID string
+	signingKey crypto.Signer
+	keys       map[string]verificationKey
+	hmacSecret []byte
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+	jwk    JWK
+}
+
+// JWK is the public half of a key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet returns a KeySet that signs with HS256 using hmacSecret until a
+// signing key is set. An empty secret disables HS256 entirely.
+func NewKeySet(hmacSecret string) *KeySet {
+	ks := &KeySet{keys: map[string]verificationKey{}}
+	if hmacSecret != "" {
+		ks.hmacSecret = []byte(hmacSecret)
+	}
+	return ks
+}
+
+// SetSigningKey makes key the one new tokens are signed with and returns its
+// key ID. RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func (ks *KeySet) SetSigningKey(key crypto.Signer) (string, error) {
+	kid, err := ks.AddVerificationKey(key.Public())
+	if err != nil {
+		return "", err
+	}
+	ks.signingKID = kid
+	ks.signingKey = key
+	return kid, nil
+}
+
+// AddVerificationKey accepts tokens signed by the private half of key and
+// returns its key ID.
+func (ks *KeySet) AddVerificationKey(key crypto.PublicKey) (string, error) {
+	var vk verificationKey
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		vk = verificationKey{
+			method: jwt.SigningMethodRS256,
+			public: pub,
+			jwk: JWK{
+				Kty: "RSA",
+				Alg: jwt.SigningMethodRS256.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			},
+		}
+	case ed25519.PublicKey:
+		vk = verificationKey{
+			method: jwt.SigningMethodEdDSA,
+			public: pub,
+			jwk: JWK{
+				Kty: "OKP",
+				Alg: jwt.SigningMethodEdDSA.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			},
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+
+	kid, err := thumbprint(vk.jwk)
+	if err != nil {
+		return "", err
+	}
+	vk.jwk.Kid = kid
+	vk.jwk.Use = "sig"
+	ks.keys[kid] = vk
+	return kid, nil
+}
+
+// thumbprint computes the RFC 7638 thumbprint of a public JWK: the SHA-256 of
+// its required members in lexical order.
+func thumbprint(jwk JWK) (string, error) {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// MakeJWT issues an access token for userID, who has role.
+func (ks *KeySet) MakeJWT(userID uuid.UUID, role string, expiresIn time.Duration) (string, error) {
+	if !ValidRole(role) {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+	return ks.sign(loginTokenClaims{
+		RegisteredClaims: accessTokenClaims(userID, expiresIn),
+		Role:             role,
+	})
+}
+
+// MakeScopedJWT issues an access token that lets OAuth client clientID act
+// for userID within scopes. ValidateJWT refuses it; use ParseJWT.
+func (ks *KeySet) MakeScopedJWT(userID uuid.UUID, clientID string, scopes []string, expiresIn time.Duration) (string, error) {
+	if len(scopes) == 0 {
+		return "", errors.New("a scoped token needs at least one scope")
+	}
+	return ks.sign(scopedTokenClaims{
+		RegisteredClaims: accessTokenClaims(userID, expiresIn),
+		ClientID:         clientID,
+		Scope:            strings.Join(scopes, " "),
+	})
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	if ks.signingKey == nil {
+		if ks.hmacSecret == nil {
+			return "", errors.New("no signing key configured")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.hmacSecret)
+	}
+
+	method := ks.keys[ks.signingKID].method
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = ks.signingKID
+	return token.SignedString(ks.signingKey)
+}
+
+// ValidateJWT checks an access token from logging in against the key named
+// by its kid and returns the user it was issued to.
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	return loginUserID(ks.ParseJWT(tokenString))
+}
+
+// ParseJWT checks an access token of either kind, from logging in or issued
+// to an OAuth client, against the key named by its kid.
+func (ks *KeySet) ParseJWT(tokenString string) (AccessToken, error) {
+	return parseAccessToken(tokenString, ks.keyFunc)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, hasKID := token.Header["kid"].(string)
+	if !hasKID {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || ks.hmacSecret == nil {
+			return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
+		}
+		return ks.hmacSecret, nil
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	// The algorithm comes from our key, never from the token
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("invalid signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWKS lists every public verification key, signing key first.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if ks.signingKey != nil {
+		jwks.Keys = append(jwks.Keys, ks.keys[ks.signingKID].jwk)
+	}
+	for _, kid := range slices.Sorted(maps.Keys(ks.keys)) {
+		if kid != ks.signingKID {
+			jwks.Keys = append(jwks.Keys, ks.keys[kid].jwk)
+		}
+	}
+	return jwks
+}
+
+// LoadSigningKey reads an RSA or Ed25519 private key from a PEM file, in
+// PKCS #8 or (for RSA) PKCS #1 form.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+	}
+	return signer, nil
+}
+
+// LoadVerificationKey reads a public key from a PEM file. A private key file
+// is accepted too, and only its public half is used.
+func LoadVerificationKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PUBLIC KEY" {
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	signer, err := LoadSigningKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
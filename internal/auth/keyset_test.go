@@ -0,0 +1,271 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestKeySetSignsAndValidates(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{"RS256", rsaKey, "RS256"},
+		{"EdDSA", edKey, "EdDSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := auth.NewKeySet("")
+			kid, err := keys.SetSigningKey(tt.key)
+			if err != nil {
+				t.Fatalf("SetSigningKey failed: %v", err)
+			}
+
+			userID := uuid.New()
+			tokenString, err := keys.MakeJWT(userID, auth.RoleUser, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT failed: %v", err)
+			}
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("parsing token header: %v", err)
+			}
+			if token.Header["kid"] != kid || token.Header["alg"] != tt.alg {
+				t.Errorf("header = %v, want kid %q alg %q", token.Header, kid, tt.alg)
+			}
+
+			validatedUserID, err := keys.ValidateJWT(tokenString)
+			if err != nil {
+				t.Fatalf("ValidateJWT failed: %v", err)
+			}
+			if validatedUserID != userID {
+				t.Errorf("ValidateJWT returned %v, want %v", validatedUserID, userID)
+			}
+
+			jwks := keys.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != kid || jwks.Keys[0].Alg != tt.alg {
+				t.Errorf("JWKS = %+v, want a single %s key %q", jwks, tt.alg, kid)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	before := auth.NewKeySet("")
+	before.SetSigningKey(oldKey)
+	oldToken, err := before.MakeJWT(uuid.New(), auth.RoleUser, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	// After rotation the old key is only trusted for verification
+	after := auth.NewKeySet("")
+	after.SetSigningKey(newKey)
+	if _, err := after.ValidateJWT(oldToken); err == nil {
+		t.Error("ValidateJWT accepted a token signed by an unknown key")
+	}
+	after.AddVerificationKey(oldKey.Public())
+	if _, err := after.ValidateJWT(oldToken); err != nil {
+		t.Errorf("ValidateJWT rejected a token signed by a verification key: %v", err)
+	}
+	if n := len(after.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys, want 2", n)
+	}
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := auth.NewKeySet("testsecret")
+	kid, _ := keys.SetSigningKey(edKey)
+
+	// An HMAC token claiming the asymmetric key's kid, signed with its
+	// public bytes as the secret
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Subject:   uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = kid
+	forged, err := token.SignedString([]byte(edKey.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatalf("signing forged token: %v", err)
+	}
+	if _, err := keys.ValidateJWT(forged); err == nil {
+		t.Error("ValidateJWT accepted an HS256 token for an EdDSA key")
+	}
+}
+
+func TestKeySetHMACFallback(t *testing.T) {
+	userID := uuid.New()
+	legacyToken, err := auth.MakeJWT(userID, "testsecret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	keys := auth.NewKeySet("testsecret")
+	if got, err := keys.ValidateJWT(legacyToken); err != nil || got != userID {
+		t.Errorf("ValidateJWT = %v, %v; want %v", got, err, userID)
+	}
+	if n := len(keys.JWKS().Keys); n != 0 {
+		t.Errorf("JWKS has %d keys, want none for HS256", n)
+	}
+
+	noSecret := auth.NewKeySet("")
+	if _, err := noSecret.ValidateJWT(legacyToken); err == nil {
+		t.Error("ValidateJWT accepted an HS256 token with HS256 disabled")
+	}
+}
+
+// The example key and thumbprint from RFC 7638 section 3.1.
+func TestKeySetThumbprint(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.Join([]string{
+		"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECP",
+		"ebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY",
+		"368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0f",
+		"M4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}, ""))
+	if err != nil {
+		t.Fatalf("decoding modulus: %v", err)
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	kid, err := auth.NewKeySet("").AddVerificationKey(key)
+	if err != nil {
+		t.Fatalf("AddVerificationKey failed: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; kid != want {
+		t.Errorf("kid = %q, want %q", kid, want)
+	}
+}
+
+func TestLoadKeysFromPEM(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("marshalling private key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("marshalling public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	privatePath := filepath.Join(dir, "signing.pem")
+	publicPath := filepath.Join(dir, "verify.pem")
+	os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644)
+
+	signer, err := auth.LoadSigningKey(privatePath)
+	if err != nil {
+		t.Fatalf("LoadSigningKey failed: %v", err)
+	}
+	if !publicKey.Equal(signer.Public()) {
+		t.Error("LoadSigningKey returned a different key")
+	}
+
+	for _, path := range []string{publicPath, privatePath} {
+		loaded, err := auth.LoadVerificationKey(path)
+		if err != nil {
+			t.Fatalf("LoadVerificationKey(%s) failed: %v", filepath.Base(path), err)
+		}
+		if !publicKey.Equal(loaded) {
+			t.Errorf("LoadVerificationKey(%s) returned a different key", filepath.Base(path))
+		}
+	}
+}
+
+func TestKeySetScopedTokens(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := auth.NewKeySet("")
+	keys.SetSigningKey(edKey)
+
+	userID := uuid.New()
+	scoped, err := keys.MakeScopedJWT(userID, "client-1", []string{auth.ScopeChirpsRead, auth.ScopeChirpsWrite}, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeScopedJWT failed: %v", err)
+	}
+
+	// A token issued to a client must never pass for a login session
+	if _, err := keys.ValidateJWT(scoped); err == nil {
+		t.Error("ValidateJWT accepted a scoped token")
+	}
+
+	token, err := keys.ParseJWT(scoped)
+	if err != nil {
+		t.Fatalf("ParseJWT failed: %v", err)
+	}
+	if token.UserID != userID || token.ClientID != "client-1" ||
+		strings.Join(token.Scopes, " ") != "chirps:read chirps:write" {
+		t.Errorf("ParseJWT = %+v", token)
+	}
+
+	login, err := keys.MakeJWT(userID, auth.RoleUser, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if token, err := keys.ParseJWT(login); err != nil || token.Scopes != nil || token.ClientID != "" {
+		t.Errorf("ParseJWT(login token) = %+v, %v", token, err)
+	}
+	if token.Role != auth.RoleUser {
+		t.Errorf("scoped token has role %q", token.Role)
+	}
+
+	if _, err := keys.MakeScopedJWT(userID, "client-1", nil, time.Hour); err == nil {
+		t.Error("MakeScopedJWT issued a token without scopes")
+	}
+}
+
+func TestKeySetRoleClaim(t *testing.T) {
+	keys := auth.NewKeySet("testsecret")
+	userID := uuid.New()
+
+	admin, err := keys.MakeJWT(userID, auth.RoleAdmin, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if token, err := keys.ParseJWT(admin); err != nil || token.Role != auth.RoleAdmin {
+		t.Errorf("ParseJWT(admin token) = %+v, %v", token, err)
+	}
+
+	// Tokens signed before roles existed carry no role claim
+	legacy, err := auth.MakeJWT(userID, "testsecret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+	if token, err := keys.ParseJWT(legacy); err != nil || token.Role != auth.RoleUser {
+		t.Errorf("ParseJWT(legacy token) = %+v, %v", token, err)
+	}
+
+	if _, err := keys.MakeJWT(userID, "root", time.Hour); err == nil {
+		t.Error("MakeJWT issued a token with an unknown role")
+	}
+}
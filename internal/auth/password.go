@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordMismatch is returned by CheckPasswordHash when the password is
+// wrong.
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// PasswordHasher makes password hashes in one format. The format and its
+// parameters are recorded in the hash, so CheckPasswordHash can verify a hash
+// from any hasher and NeedsRehash can spot ones made with old settings.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether hash was made by another algorithm or with
+	// different parameters than this hasher would use.
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idHasher hashes passwords with argon2id (RFC 9106) into the PHC
+// string format: $argon2id$v=19$m=<KiB>,t=<passes>,p=<lanes>$<salt>$<key>.
+type Argon2idHasher struct {
+	Memory      uint32 // in KiB
+	Time        uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2id uses the second recommended option of RFC 9106 with fewer
+// lanes: 64 MiB of memory and three passes.
+var DefaultArgon2id = Argon2idHasher{Memory: 64 * 1024, Time: 3, Parallelism: 2}
+
+const (
+	argon2idPrefix    = "$argon2id$"
+	argon2idSaltLen   = 16
+	argon2idKeyLen    = 32
+	bcryptHashPrefix  = "$2"
+	maxArgon2idMemory = 4 * 1024 * 1024 // 4 GiB, refuse anything sillier
+)
+
+var b64 = base64.RawStdEncoding
+
+// Hash returns an argon2id hash of password with a random salt.
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Parallelism, argon2idKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, h.Memory, h.Time, h.Parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, key, err := parseArgon2idHash(hash)
+	return err != nil || params != h || len(key) != argon2idKeyLen
+}
+
+// parseArgon2idHash splits a PHC argon2id string into its parts.
+func parseArgon2idHash(hash string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("not an argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+	if params.Memory == 0 || params.Memory > maxArgon2idMemory || params.Time == 0 || params.Parallelism == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+	if salt, err = b64.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errors.New("invalid argon2id salt")
+	}
+	if key, err = b64.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("invalid argon2id key")
+	}
+	return params, salt, key, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt at Cost.
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns a bcrypt hash of password.
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// HashPassword hashes password with DefaultArgon2id.
+func HashPassword(password string) (string, error) {
+	return DefaultArgon2id.Hash(password)
+}
+
+// CheckPasswordHash checks password against a hash made by any of the
+// hashers in this package. It returns ErrPasswordMismatch if the password is
+// wrong and another error if the hash can't be read.
+func CheckPasswordHash(password, hash string) error {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		params, salt, key, err := parseArgon2idHash(hash)
+		if err != nil {
+			return err
+		}
+		got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case strings.HasPrefix(hash, bcryptHashPrefix):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	default:
+		return errors.New("unrecognized password hash format")
+	}
+}
@@ -0,0 +1,97 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small parameters keep the tests fast; the format is the same.
+var testArgon2id = auth.Argon2idHasher{Memory: 64, Time: 1, Parallelism: 1}
+
+func TestArgon2idHashAndCheck(t *testing.T) {
+	hash, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+
+	if err := auth.CheckPasswordHash("correct horse", hash); err != nil {
+		t.Errorf("CheckPasswordHash(right password) = %v", err)
+	}
+	if err := auth.CheckPasswordHash("battery staple", hash); !errors.Is(err, auth.ErrPasswordMismatch) {
+		t.Errorf("CheckPasswordHash(wrong password) = %v, want ErrPasswordMismatch", err)
+	}
+
+	again, err := testArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if again == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestCheckPasswordHashBcrypt(t *testing.T) {
+	hash, err := auth.BcryptHasher{Cost: bcrypt.MinCost}.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if err := auth.CheckPasswordHash("correct horse", hash); err != nil {
+		t.Errorf("CheckPasswordHash(right password) = %v", err)
+	}
+	if err := auth.CheckPasswordHash("battery staple", hash); !errors.Is(err, auth.ErrPasswordMismatch) {
+		t.Errorf("CheckPasswordHash(wrong password) = %v, want ErrPasswordMismatch", err)
+	}
+}
+
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ",
+		"$argon2id$v=16$m=64,t=1,p=1$c29tZXNhbHQ$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c29tZXNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$a2V5",
+	} {
+		err := auth.CheckPasswordHash("password", hash)
+		if err == nil || errors.Is(err, auth.ErrPasswordMismatch) {
+			t.Errorf("CheckPasswordHash(%q) = %v, want a format error", hash, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argonHash, err := testArgon2id.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	bcryptHash, err := auth.BcryptHasher{Cost: bcrypt.MinCost}.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher auth.PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"same argon2id parameters", testArgon2id, argonHash, false},
+		{"more argon2id memory", auth.Argon2idHasher{Memory: 128, Time: 1, Parallelism: 1}, argonHash, true},
+		{"bcrypt to argon2id", testArgon2id, bcryptHash, true},
+		{"same bcrypt cost", auth.BcryptHasher{Cost: bcrypt.MinCost}, bcryptHash, false},
+		{"higher bcrypt cost", auth.BcryptHasher{Cost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"argon2id to bcrypt", auth.BcryptHasher{Cost: bcrypt.MinCost}, argonHash, true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
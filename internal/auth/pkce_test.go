@@ -0,0 +1,44 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"strings"
+	"testing"
+)
+
+// The example from RFC 7636 appendix B.
+func TestPKCERFC7636Example(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := auth.PKCEChallenge(verifier); got != challenge {
+		t.Errorf("PKCEChallenge = %q, want %q", got, challenge)
+	}
+	if !auth.ValidPKCEChallenge(challenge) {
+		t.Error("ValidPKCEChallenge rejected the example challenge")
+	}
+	if !auth.VerifyPKCE(verifier, challenge) {
+		t.Error("VerifyPKCE rejected the example verifier")
+	}
+}
+
+func TestVerifyPKCERejects(t *testing.T) {
+	verifier := strings.Repeat("a", 43)
+	challenge := auth.PKCEChallenge(verifier)
+
+	for name, v := range map[string]string{
+		"wrong verifier":  strings.Repeat("b", 43),
+		"too short":       strings.Repeat("a", 42),
+		"too long":        strings.Repeat("a", 129),
+		"reserved chars":  strings.Repeat("a", 42) + "+",
+		"plain challenge": challenge,
+		"empty verifier":  "",
+	} {
+		if auth.VerifyPKCE(v, challenge) {
+			t.Errorf("%s: VerifyPKCE accepted %q", name, v)
+		}
+	}
+	if auth.ValidPKCEChallenge("plain-text-challenge") {
+		t.Error("ValidPKCEChallenge accepted a non-hash challenge")
+	}
+}
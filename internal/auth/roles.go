@@ -0,0 +1,30 @@
+package auth
+
+import "slices"
+
+// Roles grant a user powers beyond their own account. Each role has every
+// power of the ones before it in Roles.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role, least privileged first.
+var Roles = []string{
+	RoleUser,
+	RoleModerator,
+	RoleAdmin,
+}
+
+// ValidRole reports whether role is one of Roles.
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// RoleAtLeast reports whether role carries the powers of required. Unknown
+// roles carry none.
+func RoleAtLeast(role, required string) bool {
+	have, want := slices.Index(Roles, role), slices.Index(Roles, required)
+	return have >= 0 && want >= 0 && have >= want
+}
@@ -0,0 +1,38 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"testing"
+)
+
+func TestRoleAtLeast(t *testing.T) {
+	cases := []struct {
+		role, required string
+		want           bool
+	}{
+		{auth.RoleAdmin, auth.RoleAdmin, true},
+		{auth.RoleAdmin, auth.RoleModerator, true},
+		{auth.RoleModerator, auth.RoleUser, true},
+		{auth.RoleModerator, auth.RoleAdmin, false},
+		{auth.RoleUser, auth.RoleModerator, false},
+		{"", auth.RoleUser, false},
+		{"root", auth.RoleUser, false},
+		{auth.RoleAdmin, "root", false},
+	}
+	for _, c := range cases {
+		if got := auth.RoleAtLeast(c.role, c.required); got != c.want {
+			t.Errorf("RoleAtLeast(%q, %q) = %v, want %v", c.role, c.required, got, c.want)
+		}
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	for _, role := range auth.Roles {
+		if !auth.ValidRole(role) {
+			t.Errorf("ValidRole(%q) = false", role)
+		}
+	}
+	if auth.ValidRole("Admin") {
+		t.Error("ValidRole accepted a role with the wrong case")
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes limit what a token that is not a full login session may do.
+const (
+	ScopeChirpsRead        = "chirps:read"
+	ScopeChirpsWrite       = "chirps:write"
+	ScopeProfileRead       = "profile:read"
+	ScopeProfileWrite      = "profile:write"
+	ScopeFollowsWrite      = "follows:write"
+	ScopeNotificationsRead = "notifications:read"
+)
+
+// Scopes lists every scope a token can be granted.
+var Scopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeProfileRead,
+	ScopeProfileWrite,
+	ScopeFollowsWrite,
+	ScopeNotificationsRead,
+}
+
+// PersonalAccessTokenPrefix starts every personal access token, which tells
+// them apart from JWTs in an Authorization header and makes leaked ones easy
+// to scan for.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// MakePersonalAccessToken returns a new random personal access token.
+func MakePersonalAccessToken() (string, error) {
+	secret, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + secret, nil
+}
+
+// IsPersonalAccessToken reports whether token looks like a personal access
+// token rather than a JWT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// ParseScopes checks requested against the known scopes and returns them
+// sorted without duplicates.
+func ParseScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	scopes := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes), nil
+}
+
+// HasScope reports whether scope is among granted.
+func HasScope(granted []string, scope string) bool {
+	return slices.Contains(granted, scope)
+}
@@ -0,0 +1,38 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := auth.ParseScopes([]string{"chirps:write", "chirps:read", "chirps:write"})
+	if err != nil {
+		t.Fatalf("ParseScopes failed: %v", err)
+	}
+	if want := []string{"chirps:read", "chirps:write"}; !slices.Equal(scopes, want) {
+		t.Errorf("ParseScopes = %v, want %v", scopes, want)
+	}
+
+	if _, err := auth.ParseScopes([]string{"chirps:read", "admin"}); err == nil {
+		t.Error("ParseScopes accepted an unknown scope")
+	}
+	if _, err := auth.ParseScopes(nil); err == nil {
+		t.Error("ParseScopes accepted no scopes")
+	}
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, auth.PersonalAccessTokenPrefix) || !auth.IsPersonalAccessToken(token) {
+		t.Errorf("token %q lacks the personal access token prefix", token)
+	}
+	if auth.IsPersonalAccessToken("eyJhbGciOiJIUzI1NiJ9.e30.sig") {
+		t.Error("IsPersonalAccessToken matched a JWT")
+	}
+}
@@ -0,0 +1,130 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test vectors from RFC 6238 appendix B.
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	seeds := map[string][]byte{
+		"SHA1":   []byte("12345678901234567890"),
+		"SHA256": []byte("12345678901234567890123456789012"),
+		"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	hashes := map[string]func() hash.Hash{
+		"SHA1":   sha1.New,
+		"SHA256": sha256.New,
+		"SHA512": sha512.New,
+	}
+
+	tests := []struct {
+		unix int64
+		alg  string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, tt := range tests {
+		totp := auth.TOTP{Digits: 8, Period: 30 * time.Second, Hash: hashes[tt.alg]}
+		if got := totp.Code(seeds[tt.alg], time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("%s at %d = %s, want %s", tt.alg, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPValidate(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret failed: %v", err)
+	}
+	if _, err := auth.DecodeTOTPSecret(secret); err != nil {
+		t.Fatalf("DecodeTOTPSecret failed: %v", err)
+	}
+
+	key := []byte("12345678901234567890")
+	now := time.Unix(1111111109, 0)
+	code := auth.DefaultTOTP.Code(key, now)
+	step, ok := auth.DefaultTOTP.Validate(key, code, now, 1)
+	if !ok || step != auth.DefaultTOTP.Step(now) {
+		t.Errorf("Validate(current code) = %d, %v", step, ok)
+	}
+
+	// One step of drift either way is accepted, two is not
+	previous := auth.DefaultTOTP.Code(key, now.Add(-30*time.Second))
+	if step, ok := auth.DefaultTOTP.Validate(key, previous, now, 1); !ok || step != auth.DefaultTOTP.Step(now)-1 {
+		t.Errorf("Validate(previous code) = %d, %v", step, ok)
+	}
+	stale := auth.DefaultTOTP.Code(key, now.Add(-60*time.Second))
+	if _, ok := auth.DefaultTOTP.Validate(key, stale, now, 1); ok {
+		t.Error("Validate accepted a code two steps old")
+	}
+	if _, ok := auth.DefaultTOTP.Validate(key, code[:5], now, 1); ok {
+		t.Error("Validate accepted a short code")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := auth.TOTPURI("JBSWY3DPEHPK3PXP", "Chirpy", "walt@example.com")
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parsing URI: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || parsed.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	if got := parsed.Query().Get("secret"); got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("secret = %q", got)
+	}
+	if got := parsed.Query().Get("issuer"); got != "Chirpy" {
+		t.Errorf("issuer = %q", got)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := auth.GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("malformed recovery code %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+
+	// Hashing ignores case, dashes and surrounding space
+	code := codes[0]
+	if auth.HashRecoveryCode(code) != auth.HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(code, "-", ""))+" ") {
+		t.Error("HashRecoveryCode is not normalizing its input")
+	}
+}
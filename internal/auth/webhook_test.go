@@ -0,0 +1,60 @@
+package auth_test
+
+import (
+	"chirpy-project/internal/auth"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedWebhookHeaders(id string, sent time.Time, signatures string) http.Header {
+	headers := http.Header{}
+	headers.Set(auth.WebhookIDHeader, id)
+	headers.Set(auth.WebhookTimestampHeader, strconv.FormatInt(sent.Unix(), 10))
+	headers.Set(auth.WebhookSignatureHeader, signatures)
+	return headers
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	oldSecret, newSecret := "old-secret", "new-secret"
+	tolerance := 5 * time.Minute
+
+	signature := auth.SignWebhook(newSecret, "evt_1", now, body)
+	headers := signedWebhookHeaders("evt_1", now, signature)
+	id, err := auth.VerifyWebhook([]string{oldSecret, newSecret}, headers, body, now, tolerance)
+	if err != nil || id != "evt_1" {
+		t.Fatalf("VerifyWebhook = %q, %v", id, err)
+	}
+
+	// During rotation the sender signs with both secrets and either is enough
+	both := auth.SignWebhook(oldSecret, "evt_1", now, body) + " " + signature
+	if _, err := auth.VerifyWebhook([]string{oldSecret}, signedWebhookHeaders("evt_1", now, both), body, now, tolerance); err != nil {
+		t.Errorf("VerifyWebhook with several signatures failed: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		secrets []string
+		headers http.Header
+		body    []byte
+		want    error
+	}{
+		{"unknown secret", []string{oldSecret}, headers, body, auth.ErrWebhookSignature},
+		{"empty secret", []string{""}, signedWebhookHeaders("evt_1", now, auth.SignWebhook("", "evt_1", now, body)), body, auth.ErrWebhookSignature},
+		{"tampered body", []string{newSecret}, headers, []byte(`{"event":"user.upgraded"}`), auth.ErrWebhookSignature},
+		{"changed id", []string{newSecret}, signedWebhookHeaders("evt_2", now, signature), body, auth.ErrWebhookSignature},
+		{"too old", []string{newSecret}, signedWebhookHeaders("evt_1", now.Add(-10*time.Minute), auth.SignWebhook(newSecret, "evt_1", now.Add(-10*time.Minute), body)), body, auth.ErrWebhookTimestamp},
+		{"from the future", []string{newSecret}, signedWebhookHeaders("evt_1", now.Add(10*time.Minute), auth.SignWebhook(newSecret, "evt_1", now.Add(10*time.Minute), body)), body, auth.ErrWebhookTimestamp},
+		{"missing headers", []string{newSecret}, http.Header{}, body, auth.ErrWebhookHeaders},
+		{"unknown version", []string{newSecret}, signedWebhookHeaders("evt_1", now, "v2,"+signature[3:]), body, auth.ErrWebhookSignature},
+	}
+	for _, c := range cases {
+		if _, err := auth.VerifyWebhook(c.secrets, c.headers, c.body, now, tolerance); !errors.Is(err, c.want) {
+			t.Errorf("%s: VerifyWebhook error = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
@@ -12,27 +12,201 @@ import (
 )
 
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	ParentID     uuid.NullUUID
+	RootID       uuid.NullUUID
+	RefChirpID   uuid.NullUUID
+	RefKind      sql.NullString
+	SearchVector interface{}
+}
+
+type ChirpEvent struct {
+	ID        int64
+	Kind      string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	RootID    uuid.NullUUID
+	CreatedAt time.Time
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int32
+	Length      int32
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type ChirpTag struct {
+	ChirpID   uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type EmailVerification struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginThrottle struct {
+	Scope         string
+	Subject       string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MfaChallenge struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Attempts  int32
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type Notification struct {
 	ID        uuid.UUID
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	ChirpID   uuid.NullUUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+	ReadAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash         string
+	ClientID         uuid.UUID
+	UserID           uuid.UUID
+	RedirectUri      string
+	RedirectUriGiven bool
+	Scopes           []string
+	CodeChallenge    string
+	FamilyID         uuid.UUID
+	ExpiresAt        time.Time
+	UsedAt           sql.NullTime
+	CreatedAt        time.Time
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	CreatedAt    time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
 	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
 }
 
 type RefreshToken struct {
-	Token     string
+	Token         string
+	UserID        uuid.UUID
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	RevokedAt     sql.NullTime
+	FamilyID      uuid.UUID
+	RevokedReason sql.NullString
+	UserAgent     string
+	IpAddress     string
+	LastUsedAt    time.Time
+	ClientID      uuid.NullUUID
+	Scopes        []string
+}
+
+type SecurityEvent struct {
+	ID        uuid.UUID
 	UserID    uuid.UUID
-	ExpiresAt time.Time
+	Kind      string
+	Detail    string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	RevokedAt sql.NullTime
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	PeriodConfirmed  bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type TotpRecoveryCode struct {
+	UserID   uuid.UUID
+	CodeHash string
+	UsedAt   sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Handle          sql.NullString
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+	Role            string
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	Secret       string
+	EnabledAt    sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type WebhookEvent struct {
+	ID         string
+	Event      string
+	ReceivedAt time.Time
 }
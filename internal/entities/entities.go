@@ -0,0 +1,80 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTagLength is the longest hashtag we store; longer runs are ignored.
+	MaxTagLength = 50
+	// MaxHandleLength is the longest handle a user can pick.
+	MaxHandleLength = 30
+)
+
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([A-Za-z0-9_]+)`)
+	handlePattern  = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// Mention is an @handle found in a chirp body. Offset and Length count
+// Unicode code points and cover the whole "@handle" including the '@'.
+type Mention struct {
+	Handle string
+	Offset int
+	Length int
+}
+
+// ExtractHashtags returns the distinct hashtags in body, normalized to
+// lowercase and without the leading '#', in order of first appearance.
+func ExtractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := NormalizeTag(match[1])
+		if tag == "" || len(tag) > MaxTagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// NormalizeTag lowercases a tag and strips a leading '#', so user input such
+// as "#Go" and "go" refer to the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// ExtractMentions returns every @handle in body in order of appearance.
+// Handles are returned as written; use NormalizeHandle to compare them.
+func ExtractMentions(body string) []Mention {
+	mentions := []Mention{}
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		handle := body[loc[2]:loc[3]]
+		if len(handle) > MaxHandleLength {
+			continue
+		}
+		// The '@' sits immediately before the captured handle
+		start := loc[2] - 1
+		mentions = append(mentions, Mention{
+			Handle: handle,
+			Offset: utf8.RuneCountInString(body[:start]),
+			Length: utf8.RuneCountInString(body[start:loc[3]]),
+		})
+	}
+	return mentions
+}
+
+// ValidHandle reports whether handle can be claimed by a user.
+func ValidHandle(handle string) bool {
+	return len(handle) <= MaxHandleLength && handlePattern.MatchString(handle)
+}
+
+// NormalizeHandle lowercases a handle; handles are case-insensitive.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(handle)
+}
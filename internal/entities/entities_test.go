@@ -0,0 +1,76 @@
+package entities_test
+
+import (
+	"chirpy-project/internal/entities"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", []string{}},
+		{"#golang is fun", []string{"golang"}},
+		{"Loving #Go and #go and #GO", []string{"go"}},
+		{"#one,#two. (#three)", []string{"one", "two", "three"}},
+		{"email me@example.com#nottag and a&#39;b", []string{}},
+		{"issue ##double", []string{}},
+		{"unicode #café_2024", []string{"café_2024"}},
+	}
+
+	for _, tt := range tests {
+		got := entities.ExtractHashtags(tt.body)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ExtractHashtags(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	if got := entities.NormalizeTag(" #Chirpy "); got != "chirpy" {
+		t.Errorf("NormalizeTag returned %q, want %q", got, "chirpy")
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []entities.Mention
+	}{
+		{"hello world", []entities.Mention{}},
+		{"@alice hi", []entities.Mention{{Handle: "alice", Offset: 0, Length: 6}}},
+		{"cc @Bob_2, @carol.", []entities.Mention{
+			{Handle: "Bob_2", Offset: 3, Length: 6},
+			{Handle: "carol", Offset: 11, Length: 6},
+		}},
+		{"mail me@example.com", []entities.Mention{}},
+		{"héllo @dave", []entities.Mention{{Handle: "dave", Offset: 6, Length: 5}}},
+		{"@" + strings.Repeat("a", entities.MaxHandleLength+1), []entities.Mention{}},
+	}
+
+	for _, tt := range tests {
+		got := entities.ExtractMentions(tt.body)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ExtractMentions(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	valid := []string{"alice", "Bob_2", strings.Repeat("a", entities.MaxHandleLength)}
+	invalid := []string{"", "has space", "dash-name", "émile", strings.Repeat("a", entities.MaxHandleLength+1)}
+
+	for _, handle := range valid {
+		if !entities.ValidHandle(handle) {
+			t.Errorf("ValidHandle(%q) = false, want true", handle)
+		}
+	}
+	for _, handle := range invalid {
+		if entities.ValidHandle(handle) {
+			t.Errorf("ValidHandle(%q) = true, want false", handle)
+		}
+	}
+}
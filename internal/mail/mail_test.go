@@ -0,0 +1,75 @@
+package mail_test
+
+import (
+	"bytes"
+	"chirpy-project/internal/mail"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err := mail.Format("chirpy@example.com", mail.Message{
+		To:      "walt@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	}, date)
+	if err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+
+	want := "From: chirpy@example.com\r\n" +
+		"To: walt@example.com\r\n" +
+		"Subject: Reset your password\r\n" +
+		"Date: Wed, 01 May 2024 12:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n" +
+		"\r\n" +
+		"line one\r\nline two"
+	if string(data) != want {
+		t.Errorf("Format returned\n%q\nwant\n%q", data, want)
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	_, err := mail.Format("chirpy@example.com", mail.Message{
+		To:      "walt@example.com\r\nBcc: everyone@example.com",
+		Subject: "hi",
+	}, time.Now())
+	if err == nil {
+		t.Error("Format accepted a recipient containing a line break")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	mailer := mail.FileMailer{Dir: dir, From: "chirpy@example.com"}
+	if err := mailer.Send(context.Background(), mail.Message{To: "walt@example.com", Subject: "hi", Body: "hello"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("found %v (%v), want one .eml file", files, err)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: walt@example.com\r\n") || !strings.HasSuffix(string(data), "\r\n\r\nhello") {
+		t.Errorf("unexpected message file:\n%s", data)
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := &mail.LogMailer{W: &buf, From: "chirpy@example.com"}
+	if err := mailer.Send(context.Background(), mail.Message{To: "walt@example.com", Subject: "hi", Body: "hello"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Subject: hi") {
+		t.Errorf("LogMailer output missing the message:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,163 @@
+package websocket_test
+
+import (
+	"bufio"
+	"chirpy-project/internal/websocket"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dial opens a raw client connection and performs the opening handshake
+// with the key from the RFC 6455 example.
+func dial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET / HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return conn, br
+}
+
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+func echoServer(t *testing.T, closed chan<- error) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEchoAndClose(t *testing.T) {
+	closed := make(chan error, 1)
+	srv := echoServer(t, closed)
+	conn, br := dial(t, srv.URL)
+
+	// A fragmented message with a ping in the middle
+	writeClientFrame(t, conn, false, websocket.TextMessage, []byte("hello "))
+	writeClientFrame(t, conn, true, websocket.PingMessage, []byte("p"))
+	writeClientFrame(t, conn, true, 0, []byte("world"))
+
+	opcode, payload := readServerFrame(t, br)
+	if opcode != websocket.PongMessage || string(payload) != "p" {
+		t.Fatalf("got opcode %d payload %q, want pong %q", opcode, payload, "p")
+	}
+	opcode, payload = readServerFrame(t, br)
+	if opcode != websocket.TextMessage || string(payload) != "hello world" {
+		t.Fatalf("got opcode %d payload %q, want text %q", opcode, payload, "hello world")
+	}
+
+	closePayload := binary.BigEndian.AppendUint16(nil, websocket.CloseGoingAway)
+	writeClientFrame(t, conn, true, websocket.CloseMessage, closePayload)
+	opcode, payload = readServerFrame(t, br)
+	if opcode != websocket.CloseMessage || binary.BigEndian.Uint16(payload) != websocket.CloseNormalClosure {
+		t.Fatalf("got opcode %d payload %v, want close echo", opcode, payload)
+	}
+
+	var closeErr *websocket.CloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("ReadMessage error = %v, want close code %d", err, websocket.CloseGoingAway)
+	}
+}
+
+func TestUnmaskedFrameIsRejected(t *testing.T) {
+	closed := make(chan error, 1)
+	srv := echoServer(t, closed)
+	conn, br := dial(t, srv.URL)
+
+	conn.Write([]byte{0x80 | websocket.TextMessage, 2, 'h', 'i'})
+
+	opcode, payload := readServerFrame(t, br)
+	if opcode != websocket.CloseMessage || binary.BigEndian.Uint16(payload) != websocket.CloseProtocolError {
+		t.Fatalf("got opcode %d payload %v, want protocol error close", opcode, payload)
+	}
+	if err := <-closed; err == nil {
+		t.Fatal("ReadMessage returned no error for an unmasked frame")
+	}
+}
+
+func TestUpgradeRequiresHandshakeHeaders(t *testing.T) {
+	srv := echoServer(t, make(chan error, 1))
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
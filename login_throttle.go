@@ -0,0 +1,226 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes stored in login_throttles.scope.
+const (
+	loginThrottleAccount = "account"
+	loginThrottleIP      = "ip"
+	// Emails sent on request, such as password resets, are counted the same
+	// way as failed logins, by the address mailed and by the asking IP.
+	mailThrottleAddress = "mail_address"
+	mailThrottleIP      = "mail_ip"
+)
+
+// loginFailureWindow is how long failures are remembered; a failure after a
+// quiet period this long starts the count again.
+const loginFailureWindow = time.Hour
+
+// loginThrottlePolicy decides how long logins are refused after a number of
+// failures in a row. The first freeFailures cost nothing, each one after that
+// doubles the delay from baseDelay up to maxDelay, and at maxFailures the
+// subject is locked out for lockout.
+type loginThrottlePolicy struct {
+	freeFailures int32
+	maxFailures  int32
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	lockout      time.Duration
+}
+
+var (
+	accountThrottlePolicy = loginThrottlePolicy{
+		freeFailures: 3,
+		maxFailures:  10,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		lockout:      15 * time.Minute,
+	}
+	// Many users can share an IP, so it gets a lot more room than an account
+	ipThrottlePolicy = loginThrottlePolicy{
+		freeFailures: 20,
+		maxFailures:  100,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		lockout:      time.Hour,
+	}
+	// A few emails to the same address are fine when one goes astray; past
+	// that it is someone filling the inbox
+	mailAddressThrottlePolicy = loginThrottlePolicy{
+		freeFailures: 3,
+		maxFailures:  10,
+		baseDelay:    time.Minute,
+		maxDelay:     15 * time.Minute,
+		lockout:      time.Hour,
+	}
+	mailIPThrottlePolicy = loginThrottlePolicy{
+		freeFailures: 20,
+		maxFailures:  100,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		lockout:      time.Hour,
+	}
+)
+
+func (p loginThrottlePolicy) delay(failures int32) time.Duration {
+	if failures >= p.maxFailures {
+		return p.lockout
+	}
+	if failures <= p.freeFailures {
+		return 0
+	}
+	delay := p.baseDelay
+	for i := p.freeFailures + 1; i < failures && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.maxDelay)
+}
+
+// loginAccountKey normalizes an email so that case and stray spaces don't
+// give an attacker a fresh counter.
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginRetryAfter returns how long the caller has to wait before a login for
+// email from ip will be attempted, or zero if it may go ahead.
+func (cfg *apiConfig) loginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+	return cfg.throttleRetryAfter(ctx,
+		database.GetLoginThrottleParams{Scope: loginThrottleAccount, Subject: loginAccountKey(email)},
+		database.GetLoginThrottleParams{Scope: loginThrottleIP, Subject: ip},
+	)
+}
+
+// throttleRetryAfter returns the longest lock still running on any of keys.
+func (cfg *apiConfig) throttleRetryAfter(ctx context.Context, keys ...database.GetLoginThrottleParams) (time.Duration, error) {
+	now := time.Now().UTC()
+	var wait time.Duration
+	for _, key := range keys {
+		throttle, err := cfg.dbQueries.GetLoginThrottle(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if throttle.LockedUntil.Valid && throttle.LockedUntil.Time.After(now) {
+			wait = max(wait, throttle.LockedUntil.Time.Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed login against both the account and the
+// IP and locks whichever has gone over its allowance. userID is set when the
+// email belongs to a user, so a lockout shows up in their security events.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, email, ip string, userID uuid.NullUUID) error {
+	failures, delay, err := cfg.recordThrottleHit(ctx, loginThrottleAccount, loginAccountKey(email), accountThrottlePolicy)
+	if err != nil {
+		return err
+	}
+	if _, _, err := cfg.recordThrottleHit(ctx, loginThrottleIP, ip, ipThrottlePolicy); err != nil {
+		return err
+	}
+
+	if failures == accountThrottlePolicy.maxFailures && userID.Valid {
+		detail := fmt.Sprintf("locked for %s after %d failed logins, last from %s", delay, failures, ip)
+		if err := logSecurityEvent(ctx, cfg.dbQueries, userID.UUID, securityEventLoginLockout, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordThrottleHit counts one more hit against subject in scope, starting
+// the count again after loginFailureWindow without one, and locks it for as
+// long as policy says. It returns the count and the lock, if any.
+func (cfg *apiConfig) recordThrottleHit(ctx context.Context, scope, subject string, policy loginThrottlePolicy) (int32, time.Duration, error) {
+	now := time.Now().UTC()
+	throttle, err := cfg.dbQueries.RecordThrottleHit(ctx, database.RecordThrottleHitParams{
+		Scope:         scope,
+		Subject:       subject,
+		LastFailureAt: now.Add(-loginFailureWindow),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	delay := policy.delay(throttle.Failures)
+	if delay == 0 {
+		return throttle.Failures, 0, nil
+	}
+	err = cfg.dbQueries.LockThrottle(ctx, database.LockThrottleParams{
+		Scope:       scope,
+		Subject:     subject,
+		LockedUntil: sql.NullTime{Time: now.Add(delay), Valid: true},
+	})
+	return throttle.Failures, delay, err
+}
+
+// clearLoginFailures forgets the failures against an account once its owner
+// has logged in. The IP's count is left alone: one good login from an IP
+// says nothing about the other accounts it has been trying.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, email string) error {
+	_, err := cfg.dbQueries.ClearLoginThrottle(ctx, database.ClearLoginThrottleParams{
+		Scope:   loginThrottleAccount,
+		Subject: loginAccountKey(email),
+	})
+	return err
+}
+
+// mailRetryAfter returns how long the caller has to wait before another email
+// will be sent to address at the request of ip, or zero if it may go ahead.
+func (cfg *apiConfig) mailRetryAfter(ctx context.Context, address, ip string) (time.Duration, error) {
+	return cfg.throttleRetryAfter(ctx,
+		database.GetLoginThrottleParams{Scope: mailThrottleAddress, Subject: loginAccountKey(address)},
+		database.GetLoginThrottleParams{Scope: mailThrottleIP, Subject: ip},
+	)
+}
+
+// recordMailSent counts an email sent to address at the request of ip and
+// locks whichever has gone over its allowance. Unlike failed logins, nothing
+// clears the count; it runs out after loginFailureWindow without a request.
+func (cfg *apiConfig) recordMailSent(ctx context.Context, address, ip string) error {
+	if _, _, err := cfg.recordThrottleHit(ctx, mailThrottleAddress, loginAccountKey(address), mailAddressThrottlePolicy); err != nil {
+		return err
+	}
+	_, _, err := cfg.recordThrottleHit(ctx, mailThrottleIP, ip, mailIPThrottlePolicy)
+	return err
+}
+
+// throttleMail checks and counts an email the request is about to have sent
+// to address. It reports whether the email may go out, having responded with
+// 429 already if not.
+func (cfg *apiConfig) throttleMail(w http.ResponseWriter, r *http.Request, address string) bool {
+	ip := sessionClientFromRequest(r).IPAddress
+
+	retryAfter, err := cfg.mailRetryAfter(r.Context(), address, ip)
+	if err != nil {
+		fmt.Printf("Error checking email throttle: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to check email attempts")
+		return false
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many emails requested, try again later")
+		return false
+	}
+
+	if err := cfg.recordMailSent(r.Context(), address, ip); err != nil {
+		fmt.Printf("Error recording email request: %v\n", err)
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"chirpy-project/internal/mail"
+	"os"
+)
+
+// loadMailer picks how email is sent from the environment: through SMTP when
+// MAIL_SMTP_ADDR is set, into .eml files when MAIL_DIR is set, and otherwise
+// printed to stdout so development needs no mail server.
+func loadMailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	if addr := os.Getenv("MAIL_SMTP_ADDR"); addr != "" {
+		return mail.SMTPMailer{
+			Addr:     addr,
+			Username: os.Getenv("MAIL_SMTP_USERNAME"),
+			Password: os.Getenv("MAIL_SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return mail.FileMailer{Dir: dir, From: from}
+	}
+	return &mail.LogMailer{W: os.Stdout, From: from}
+}
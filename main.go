@@ -1,23 +1,42 @@
 package main
 
 import (
+	"chirpy-project/internal/auth"
 	"chirpy-project/internal/database"
+	"chirpy-project/internal/mail"
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platform       string
-	jwtSecret      string
-	polkaKey       string
+	fileserverHits  atomic.Int32
+	db              *sql.DB
+	dbQueries       *database.Queries
+	platform        string
+	jwtKeys         *auth.KeySet
+	passwordHasher  auth.PasswordHasher
+	polkaSecrets    []string
+	editRequiresRed bool
+	// Whether only users with a verified email may post chirps
+	requireVerifiedEmail bool
+	chirpHub             *chirpHub
+	mailer               mail.Mailer
+	baseURL              string
+	adminEmail           string
+	wsConns              sync.WaitGroup
 }
 
 func main() {
@@ -39,8 +58,40 @@ func main() {
 	dbQueries := database.New(db)
 
 	jwt_secret := os.Getenv("JWT_SECRET")
+	jwtKeys, err := loadJWTKeys(jwt_secret)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	passwordHasher, err := loadPasswordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Polka webhooks are signed with one of these comma-separated secrets;
+	// list the old and new ones together while rotating
+	var polkaSecrets []string
+	for _, secret := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			polkaSecrets = append(polkaSecrets, secret)
+		}
+	}
+
+	// Links in emails point here
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	// Editing chirps can be limited to Chirpy Red members
+	editRequiresRed := os.Getenv("CHIRP_EDIT_REQUIRES_RED") == "true"
+
+	// Posting can be limited to users who have verified their email
+	requireVerifiedEmail := os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
 
-	polka_key := os.Getenv("POLKA_KEY")
+	// The account with this address becomes an admin once it is verified,
+	// so the first admin doesn't have to be made in the database
+	adminEmail := strings.TrimSpace(os.Getenv("ADMIN_EMAIL"))
 
 	const filepathRoot = "."
 	const port = "8080"
@@ -48,28 +99,95 @@ func main() {
 	mux := http.NewServeMux()
 
 	cfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		dbQueries:      dbQueries,
-		platform:       platform,
-		jwtSecret:      jwt_secret,
-		polkaKey:       polka_key,
+		fileserverHits:       atomic.Int32{},
+		db:                   db,
+		dbQueries:            dbQueries,
+		platform:             platform,
+		jwtKeys:              jwtKeys,
+		passwordHasher:       passwordHasher,
+		polkaSecrets:         polkaSecrets,
+		editRequiresRed:      editRequiresRed,
+		requireVerifiedEmail: requireVerifiedEmail,
+		chirpHub:             newChirpHub(dbQueries),
+		mailer:               loadMailer(),
+		baseURL:              strings.TrimSuffix(baseURL, "/"),
+		adminEmail:           adminEmail,
+	}
+
+	// Stop on Ctrl-C or SIGTERM; the hub goes first so live streams and
+	// WebSockets are told to go away before the server stops
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hubDone := make(chan struct{})
+	go func() {
+		cfg.chirpHub.run(ctx, dbURL)
+		close(hubDone)
+	}()
+	go cfg.expireSubscriptions(ctx)
+	if err := cfg.bootstrapAdmin(ctx); err != nil {
+		log.Printf("Error granting admin to ADMIN_EMAIL: %v\n", err)
 	}
 	// Initialize apiConfig
 
 	mux.HandleFunc("GET /api/healthz", healthzHandler) // Register healthzHandler for /healthz path
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.jwksHandler)
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", cfg.oauthMetadataHandler)
+	mux.HandleFunc("GET /oauth/authorize", cfg.authorizeHandler)
+	mux.HandleFunc("POST /oauth/authorize", cfg.authorizeDecisionHandler)
+	mux.HandleFunc("POST /oauth/token", cfg.oauthTokenHandler)
+	mux.HandleFunc("POST /oauth/revoke", cfg.oauthRevokeHandler)
 	mux.Handle("/app/", http.StripPrefix("/app/", cfg.middlewareMetricsInc(http.FileServer(http.Dir(filepathRoot)))))
-	mux.HandleFunc("GET /admin/metrics", cfg.metricsHandler)
-	mux.HandleFunc("POST /admin/reset", cfg.resetHandler)
+	mux.HandleFunc("GET /admin/metrics", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.metricsHandler))
+	mux.HandleFunc("POST /admin/reset", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.resetHandler))
+	mux.HandleFunc("DELETE /admin/login-locks", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.clearLoginLockHandler))
+	mux.HandleFunc("PUT /admin/users/{id}/role", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.setUserRoleHandler))
 	mux.HandleFunc("POST /api/chirps", cfg.createChirpHandler) // Added cfg. to validateChirpHandler
 	mux.HandleFunc("POST /api/users", cfg.createUserHandler)
 	mux.HandleFunc("GET /api/chirps", cfg.listChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpid}", cfg.getChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpid}/thread", cfg.threadHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpid}/likes", cfg.likeChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpid}/likes", cfg.unlikeChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpid}/rechirp", cfg.rechirpHandler)
 	mux.HandleFunc("POST /api/login", cfg.loginHandler)
+	mux.HandleFunc("POST /api/login/mfa", cfg.mfaLoginHandler)
+	mux.HandleFunc("POST /api/email/verify", cfg.verifyEmailHandler)
+	mux.HandleFunc("POST /api/email/verify/resend", cfg.resendVerificationHandler)
+	mux.HandleFunc("POST /api/password/forgot", cfg.forgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", cfg.resetPasswordHandler)
 	mux.HandleFunc("POST /api/refresh", cfg.refreshTokenHandler)
 	mux.HandleFunc("POST /api/revoke", cfg.revokeRefreshTokenHandler)
+	mux.HandleFunc("GET /api/sessions", cfg.listSessionsHandler)
+	mux.HandleFunc("DELETE /api/sessions/{id}", cfg.revokeSessionHandler)
+	mux.HandleFunc("POST /api/sessions/revoke-all", cfg.revokeAllSessionsHandler)
+	mux.HandleFunc("POST /api/oauth/clients", cfg.createOAuthClientHandler)
+	mux.HandleFunc("GET /api/oauth/clients", cfg.listOAuthClientsHandler)
+	mux.HandleFunc("DELETE /api/oauth/clients/{id}", cfg.deleteOAuthClientHandler)
+	mux.HandleFunc("POST /api/tokens", cfg.createTokenHandler)
+	mux.HandleFunc("GET /api/tokens", cfg.listTokensHandler)
+	mux.HandleFunc("DELETE /api/tokens/{id}", cfg.revokeTokenHandler)
 	mux.HandleFunc("PUT /api/users", cfg.updateUserHandler)
+	mux.HandleFunc("POST /api/users/2fa/enroll", cfg.enrollTOTPHandler)
+	mux.HandleFunc("POST /api/users/2fa/verify", cfg.verifyTOTPHandler)
+	mux.HandleFunc("DELETE /api/users/2fa", cfg.disableTOTPHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpid}", cfg.deleteChirpHandler)
-	mux.HandleFunc("POST /api/polka/webhooks", cfg.upgradeUserHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpid}", cfg.updateChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpid}/revisions", cfg.listChirpRevisionsHandler)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.polkaWebhookHandler)
+	mux.HandleFunc("GET /api/users/me/subscription", cfg.getSubscriptionHandler)
+	mux.HandleFunc("POST /api/users/{id}/follow", cfg.followUserHandler)
+	mux.HandleFunc("DELETE /api/users/{id}/follow", cfg.unfollowUserHandler)
+	mux.HandleFunc("GET /api/users/{id}/followers", cfg.listFollowersHandler)
+	mux.HandleFunc("GET /api/users/{id}/following", cfg.listFollowingHandler)
+	mux.HandleFunc("GET /api/timeline", cfg.timelineHandler)
+	mux.HandleFunc("GET /api/search/chirps", cfg.searchChirpsHandler)
+	mux.HandleFunc("GET /api/tags/trending", cfg.trendingTagsHandler)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", cfg.tagChirpsHandler)
+	mux.HandleFunc("GET /api/notifications", cfg.listNotificationsHandler)
+	mux.HandleFunc("POST /api/notifications/read", cfg.markNotificationsReadHandler)
+	mux.HandleFunc("GET /api/notifications/unread_count", cfg.unreadNotificationCountHandler)
+	mux.HandleFunc("GET /api/stream/chirps", cfg.streamChirpsHandler)
+	mux.HandleFunc("GET /api/ws", cfg.websocketHandler)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
@@ -77,5 +195,32 @@ func main() {
 	}
 
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+	<-hubDone
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v\n", err)
+	}
+
+	// Shutdown does not track hijacked connections, so wait for the
+	// WebSockets separately
+	wsDone := make(chan struct{})
+	go func() {
+		cfg.wsConns.Wait()
+		close(wsDone)
+	}()
+	select {
+	case <-wsDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for websockets to close")
+	}
 }
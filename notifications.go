@@ -0,0 +1,30 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Notification kinds stored in notifications.kind.
+const (
+	notificationKindMention = "mention"
+	notificationKindReply   = "reply"
+	notificationKindLike    = "like"
+	notificationKindFollow  = "follow"
+)
+
+// notify records a notification for recipient about something actor did.
+// Users are never notified about their own actions.
+func notify(ctx context.Context, q *database.Queries, recipient, actor uuid.UUID, kind string, chirpID uuid.UUID) error {
+	if recipient == actor {
+		return nil
+	}
+	return q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  recipient,
+		ActorID: actor,
+		Kind:    kind,
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	})
+}
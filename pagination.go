@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// pageCursor is the decoded form of an opaque keyset cursor. It points at the
+// (created_at, id) of the last row seen, plus its rank for relevance-ordered
+// results; Backward is set when the cursor was handed out as a prev_cursor and
+// the page should be read towards the start.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Rank      float32
+	Backward  bool
+}
+
+func encodeCursor(key pageKey, backward bool) string {
+	direction := "n"
+	if backward {
+		direction = "p"
+	}
+	raw := fmt.Sprintf("%s|%s|%s|%s",
+		direction,
+		key.CreatedAt.UTC().Format(time.RFC3339Nano),
+		key.ID.String(),
+		strconv.FormatFloat(float64(key.Rank), 'g', -1, 32),
+	)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 || (parts[0] != "n" && parts[0] != "p") {
+		return pageCursor{}, fmt.Errorf("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor id: %w", err)
+	}
+	rank, err := strconv.ParseFloat(parts[3], 32)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor rank: %w", err)
+	}
+	return pageCursor{CreatedAt: createdAt, ID: id, Rank: float32(rank), Backward: parts[0] == "p"}, nil
+}
+
+// The param helpers turn a possibly-nil cursor into the nullable query
+// arguments the keyset queries expect; all are NULL on the first page.
+
+func (c *pageCursor) createdAtParam() sql.NullTime {
+	if c == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: c.CreatedAt, Valid: true}
+}
+
+func (c *pageCursor) idParam() uuid.NullUUID {
+	if c == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: c.ID, Valid: true}
+}
+
+func (c *pageCursor) rankParam() sql.NullFloat64 {
+	if c == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(c.Rank), Valid: true}
+}
+
+// parsePageParams reads the `limit` and `cursor` query parameters shared by
+// every paginated endpoint. A nil cursor means "start from the first page".
+func parsePageParams(query url.Values) (int, *pageCursor, error) {
+	limit := defaultPageLimit
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			return 0, nil, fmt.Errorf("invalid limit")
+		}
+		limit = min(parsed, maxPageLimit)
+	}
+
+	raw := query.Get("cursor")
+	if raw == "" {
+		return limit, nil, nil
+	}
+	cursor, err := decodeCursor(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+	return limit, &cursor, nil
+}
+
+// pageKey is the (created_at, id) pair a page row is ordered by. Rank is only
+// set for relevance-ordered results and is zero everywhere else.
+type pageKey struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Rank      float32
+}
+
+// pageInfo works out next/prev cursors for a page that was fetched with
+// limit+1 rows. keys must already be in display order with the extra row
+// trimmed; hasMore reports whether that extra row existed.
+func pageInfo(keys []pageKey, cursor *pageCursor, hasMore bool) (next, prev string) {
+	if len(keys) == 0 {
+		return "", ""
+	}
+	first, last := keys[0], keys[len(keys)-1]
+
+	backward := cursor != nil && cursor.Backward
+	// Reading forward, there is a later page only if we over-fetched; reading
+	// backward, we always came from a later page.
+	if backward || hasMore {
+		next = encodeCursor(last, false)
+	}
+	// Reading forward from a cursor, there is always an earlier page; reading
+	// backward, only if we over-fetched.
+	if (cursor != nil && !backward) || (backward && hasMore) {
+		prev = encodeCursor(first, true)
+	}
+	return next, prev
+}
+
+// keysetQuery runs one page query against the database. desc selects the
+// ORDER BY direction and cursor is nil on the first page.
+type keysetQuery[T any] func(desc bool, cursor *pageCursor, rowLimit int32) ([]T, error)
+
+// fetchPage reads one page in display order. It asks for limit+1 rows so the
+// caller can tell whether another page exists, and runs the query in the
+// opposite direction when paging backward.
+func fetchPage[T any](sortDesc bool, limit int, cursor *pageCursor, query keysetQuery[T]) ([]T, bool, error) {
+	backward := cursor != nil && cursor.Backward
+
+	rows, err := query(sortDesc != backward, cursor, int32(limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if backward {
+		slices.Reverse(rows)
+	}
+	return rows, hasMore, nil
+}
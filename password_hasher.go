@@ -0,0 +1,80 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"chirpy-project/internal/database"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loadPasswordHasher picks how new passwords are hashed from the
+// environment. PASSWORD_HASHER is argon2id (the default) or bcrypt; argon2id
+// is tuned with ARGON2_MEMORY_KIB, ARGON2_TIME and ARGON2_PARALLELISM, and
+// bcrypt with BCRYPT_COST. Existing hashes in another format or with other
+// settings are upgraded when their owner next logs in.
+func loadPasswordHasher() (auth.PasswordHasher, error) {
+	switch name := os.Getenv("PASSWORD_HASHER"); name {
+	case "", "argon2id":
+		h := auth.DefaultArgon2id
+		memory, err := envUint("ARGON2_MEMORY_KIB", uint64(h.Memory), 32)
+		if err != nil {
+			return nil, err
+		}
+		time, err := envUint("ARGON2_TIME", uint64(h.Time), 32)
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := envUint("ARGON2_PARALLELISM", uint64(h.Parallelism), 8)
+		if err != nil {
+			return nil, err
+		}
+		h.Memory, h.Time, h.Parallelism = uint32(memory), uint32(time), uint8(parallelism)
+		if h.Memory < 8*uint32(h.Parallelism) || h.Time == 0 || h.Parallelism == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", h.Memory, h.Time, h.Parallelism)
+		}
+		return h, nil
+	case "bcrypt":
+		cost, err := envUint("BCRYPT_COST", uint64(bcrypt.DefaultCost), 8)
+		if err != nil {
+			return nil, err
+		}
+		if int(cost) < bcrypt.MinCost || int(cost) > bcrypt.MaxCost {
+			return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return auth.BcryptHasher{Cost: int(cost)}, nil
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASHER %q", name)
+	}
+}
+
+// envUint reads an unsigned integer of the given bit size from the
+// environment, falling back to def when the variable is unset.
+func envUint(name string, def uint64, bitSize int) (uint64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n, nil
+}
+
+// rehashPassword stores a fresh hash of password for userID, made with the
+// configured hasher.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hashedPassword, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return cfg.dbQueries.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"chirpy-project/internal/auth"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestAuth is who a request acts for and what it may do.
+type requestAuth struct {
+	UserID uuid.UUID
+	Role   string
+	// Scopes granted to a personal access token or an OAuth client; nil for
+	// a login session, which may do anything
+	Scopes []string
+}
+
+func (a requestAuth) can(scope string) bool {
+	return a.isLoginSession() || auth.HasScope(a.Scopes, scope)
+}
+
+func (a requestAuth) isLoginSession() bool {
+	return a.Scopes == nil
+}
+
+// hasRole reports whether the request carries the powers of role. Tokens
+// limited to scopes never do, whoever they belong to.
+func (a requestAuth) hasRole(role string) bool {
+	return a.isLoginSession() && auth.RoleAtLeast(a.Role, role)
+}
+
+// missingScopeError means the caller's token is valid but was not granted
+// the scope the endpoint needs.
+type missingScopeError struct {
+	scope string
+}
+
+func (e missingScopeError) Error() string {
+	return fmt.Sprintf("token is missing the %s scope", e.scope)
+}
+
+var errInvalidAccessToken = errors.New("invalid or expired access token")
+
+// requestUserID extracts the bearer JWT from the request and returns the user
+// it was issued to. Only a login session will do, so this guards the
+// endpoints that manage the account's security; everything else goes
+// through authorizeRequest.
+func (cfg *apiConfig) requestUserID(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return cfg.jwtKeys.ValidateJWT(token)
+}
+
+// authorizeRequest returns the user a request acts for if its bearer token
+// is a login JWT, or a personal access token or OAuth access token granted
+// scope.
+func (cfg *apiConfig) authorizeRequest(r *http.Request, scope string) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return cfg.authorizeToken(r.Context(), token, scope)
+}
+
+// authorizeToken is authorizeRequest for a bearer token already taken from
+// the request.
+func (cfg *apiConfig) authorizeToken(ctx context.Context, token, scope string) (uuid.UUID, error) {
+	ra, err := cfg.authenticateToken(ctx, token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !ra.can(scope) {
+		return uuid.Nil, missingScopeError{scope: scope}
+	}
+	return ra.UserID, nil
+}
+
+// authenticateToken checks a bearer token of either kind.
+func (cfg *apiConfig) authenticateToken(ctx context.Context, token string) (requestAuth, error) {
+	if !auth.IsPersonalAccessToken(token) {
+		accessToken, err := cfg.jwtKeys.ParseJWT(token)
+		if err != nil {
+			return requestAuth{}, err
+		}
+		return requestAuth{UserID: accessToken.UserID, Role: accessToken.Role, Scopes: accessToken.Scopes}, nil
+	}
+
+	pat, err := cfg.dbQueries.GetPersonalAccessToken(ctx, auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return requestAuth{}, errInvalidAccessToken
+	}
+	if err != nil {
+		return requestAuth{}, err
+	}
+	if pat.ExpiresAt.Valid && pat.ExpiresAt.Time.Before(time.Now().UTC()) {
+		return requestAuth{}, errInvalidAccessToken
+	}
+	if err := cfg.dbQueries.TouchPersonalAccessToken(ctx, pat.ID); err != nil {
+		fmt.Printf("Error updating personal access token last use: %v\n", err)
+	}
+	return requestAuth{UserID: pat.UserID, Role: auth.RoleUser, Scopes: pat.Scopes}, nil
+}
+
+type requestAuthKey struct{}
+
+// middlewareRequireRole only lets requests from a login session of a user
+// with at least role through to next. The role is the one in the access
+// token, so a change of role takes effect when the token is next refreshed.
+func (cfg *apiConfig) middlewareRequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		ra, err := cfg.authenticateToken(r.Context(), token)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !ra.hasRole(role) {
+			respondWithError(w, http.StatusForbidden, "Requires the "+role+" role")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), requestAuthKey{}, ra)))
+	}
+}
+
+// requestAuthFromContext returns the caller middlewareRequireRole let
+// through.
+func requestAuthFromContext(ctx context.Context) requestAuth {
+	ra, _ := ctx.Value(requestAuthKey{}).(requestAuth)
+	return ra
+}
+
+// optionalRequestUserID is authorizeRequest for endpoints that also serve
+// anonymous callers: a missing or invalid token, or one without chirps:read,
+// simply yields no user.
+func (cfg *apiConfig) optionalRequestUserID(r *http.Request) uuid.NullUUID {
+	userID, err := cfg.authorizeRequest(r, auth.ScopeChirpsRead)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// respondWithAuthError answers a request authorizeRequest turned down.
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	var scopeErr missingScopeError
+	if errors.As(err, &scopeErr) {
+		respondWithError(w, http.StatusForbidden, "Token is missing the "+scopeErr.scope+" scope")
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+}
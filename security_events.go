@@ -0,0 +1,29 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Security event kinds stored in security_events.kind.
+const (
+	securityEventRefreshTokenReuse = "refresh_token_reuse"
+	securityEventPasswordReset     = "password_reset"
+	securityEventLoginLockout      = "login_lockout"
+	securityEventOAuthCodeReuse    = "oauth_code_reuse"
+	securityEventRoleChanged       = "role_changed"
+)
+
+// logSecurityEvent records something suspicious that happened to userID's
+// account, and prints it so it shows up in the server log as well.
+func logSecurityEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, kind, detail string) error {
+	fmt.Printf("Security event %s for user %s: %s\n", kind, userID, detail)
+	return q.CreateSecurityEvent(ctx, database.CreateSecurityEventParams{
+		UserID: userID,
+		Kind:   kind,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,9 @@
+-- name: ListChirpEventsAfter :many
+SELECT * FROM chirp_events
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
+
+-- name: DeleteChirpEventsBefore :exec
+DELETE FROM chirp_events
+WHERE created_at < $1;
@@ -0,0 +1,24 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE scope = $1 AND subject = $2;
+
+-- name: RecordThrottleHit :one
+INSERT INTO login_throttles (scope, subject, failures, last_failure_at)
+VALUES ($1, $2, 1, NOW())
+ON CONFLICT (scope, subject) DO UPDATE
+SET
+    failures = CASE
+        WHEN login_throttles.last_failure_at < $3 THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: LockThrottle :exec
+UPDATE login_throttles
+SET locked_until = $3
+WHERE scope = $1 AND subject = $2;
+
+-- name: ClearLoginThrottle :execrows
+DELETE FROM login_throttles
+WHERE scope = $1 AND subject = $2;
@@ -0,0 +1,22 @@
+-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, length)
+SELECT
+    sqlc.arg('chirp_id'),
+    unnest(sqlc.arg('user_ids')::uuid[]),
+    unnest(sqlc.arg('offsets')::integer[]),
+    unnest(sqlc.arg('lengths')::integer[]);
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListMentionedUserIDs :many
+SELECT DISTINCT user_id FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListMentionsForChirps :many
+SELECT chirp_mentions.chirp_id, chirp_mentions.user_id, chirp_mentions.start_offset, chirp_mentions.length, users.handle
+FROM chirp_mentions
+JOIN users ON users.id = chirp_mentions.user_id
+WHERE chirp_mentions.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_mentions.chirp_id, chirp_mentions.start_offset;
@@ -0,0 +1,53 @@
+-- name: SetPendingTOTP :execrows
+INSERT INTO user_totp (user_id, secret, enabled_at, last_used_step, created_at)
+VALUES ($1, $2, NULL, 0, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+created_at = NOW()
+WHERE user_totp.enabled_at IS NULL;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+
+-- name: EnableUserTOTP :execrows
+UPDATE user_totp
+SET enabled_at = NOW(),
+last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: AddRecoveryCodes :exec
+INSERT INTO totp_recovery_codes (user_id, code_hash)
+SELECT sqlc.arg('user_id'), unnest(sqlc.arg('code_hashes')::text[]);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (token_hash, user_id, attempts, expires_at, created_at)
+VALUES ($1, $2, 0, $3, NOW());
+
+-- name: RecordMFAChallengeAttempt :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1
+RETURNING *;
+
+-- name: DeleteMFAChallenge :exec
+DELETE FROM mfa_challenges
+WHERE token_hash = $1;
@@ -1,6 +1,6 @@
 -- name: CreateRefreshToken :one
-insert into refresh_tokens (token, user_id, expires_at, created_at, updated_at, revoked_at)
-VALUES ($1, $2, $3, NOW(), NOW(), $4)
+insert into refresh_tokens (token, user_id, expires_at, created_at, updated_at, revoked_at, family_id, user_agent, ip_address, last_used_at, client_id, scopes)
+VALUES ($1, $2, $3, NOW(), NOW(), $4, $5, $6, $7, NOW(), $8, $9)
 RETURNING *;
 
 -- name: GetUserFromRefreshToken :one
@@ -14,5 +14,48 @@ WHERE token = $1;
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(),
+revoked_reason = 'revoked',
 updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+revoked_reason = 'rotated',
+updated_at = NOW()
+WHERE token = $1 AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+revoked_reason = 'revoked',
+updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+SELECT
+    live.family_id,
+    live.user_agent,
+    live.ip_address,
+    (SELECT MIN(family.created_at) FROM refresh_tokens family WHERE family.family_id = live.family_id)::timestamp AS started_at,
+    live.last_used_at,
+    live.expires_at
+FROM refresh_tokens live
+WHERE live.user_id = $1
+AND live.revoked_at IS NULL
+AND live.expires_at > NOW()
+ORDER BY live.last_used_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+revoked_reason = 'revoked',
+updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllUserSessions :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+revoked_reason = 'revoked',
+updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,35 @@
+-- name: SearchChirpsDesc :many
+SELECT sqlc.embed(chirps),
+    ts_rank(chirps.search_vector, tsq)::real AS rank,
+    ts_headline('english', translate(chirps.body, chr(57344) || chr(57345), ''), tsq,
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2')::text AS snippet
+FROM chirps, websearch_to_tsquery('english', sqlc.arg('query')) AS tsq
+WHERE chirps.search_vector @@ tsq
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+AND (
+    sqlc.narg('cursor_rank')::real IS NULL
+    OR (ts_rank(chirps.search_vector, tsq)::real, chirps.created_at, chirps.id)
+        < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: SearchChirpsAsc :many
+SELECT sqlc.embed(chirps),
+    ts_rank(chirps.search_vector, tsq)::real AS rank,
+    ts_headline('english', translate(chirps.body, chr(57344) || chr(57345), ''), tsq,
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2')::text AS snippet
+FROM chirps, websearch_to_tsquery('english', sqlc.arg('query')) AS tsq
+WHERE chirps.search_vector @@ tsq
+AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+AND (sqlc.narg('since')::timestamp IS NULL OR chirps.created_at >= sqlc.narg('since')::timestamp)
+AND (sqlc.narg('until')::timestamp IS NULL OR chirps.created_at < sqlc.narg('until')::timestamp)
+AND (
+    sqlc.narg('cursor_rank')::real IS NULL
+    OR (ts_rank(chirps.search_vector, tsq)::real, chirps.created_at, chirps.id)
+        > (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY rank ASC, chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('row_limit');
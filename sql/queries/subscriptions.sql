@@ -0,0 +1,43 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, period_confirmed, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET
+    plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    period_confirmed = EXCLUDED.period_confirmed,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET
+    status = $2,
+    updated_at = NOW()
+WHERE
+    user_id = $1
+RETURNING *;
+
+-- name: ExpireSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET
+        status = 'expired',
+        updated_at = NOW()
+    WHERE
+        status IN ('active', 'past_due')
+        AND period_confirmed
+        AND current_period_end <= NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET
+    is_chirpy_red = FALSE,
+    updated_at = NOW()
+FROM expired
+WHERE users.id = expired.user_id
+RETURNING users.id;
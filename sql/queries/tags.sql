@@ -0,0 +1,27 @@
+-- name: AddChirpTags :exec
+INSERT INTO chirp_tags (chirp_id, tag, created_at)
+SELECT sqlc.arg('chirp_id'), unnest(sqlc.arg('tags')::text[]), NOW()
+ON CONFLICT (chirp_id, tag) DO NOTHING;
+
+-- name: DeleteChirpTagsExcept :exec
+DELETE FROM chirp_tags
+WHERE chirp_id = sqlc.arg('chirp_id')
+AND NOT (tag = ANY(COALESCE(sqlc.arg('keep')::text[], '{}')));
+
+-- name: ListTrendingTags :many
+SELECT
+    tag,
+    COUNT(*) FILTER (WHERE created_at >= sqlc.arg('window_start')::timestamp) AS recent_count,
+    COUNT(*) FILTER (WHERE created_at < sqlc.arg('window_start')::timestamp) AS baseline_count,
+    (
+        (COUNT(*) FILTER (WHERE created_at >= sqlc.arg('window_start')::timestamp))::float8 + 1
+    ) / (
+        (COUNT(*) FILTER (WHERE created_at < sqlc.arg('window_start')::timestamp))::float8
+        * sqlc.arg('window_fraction')::float8 + 1
+    ) AS score
+FROM chirp_tags
+WHERE created_at >= sqlc.arg('baseline_start')::timestamp
+GROUP BY tag
+HAVING COUNT(*) FILTER (WHERE created_at >= sqlc.arg('window_start')::timestamp) > 0
+ORDER BY score DESC, recent_count DESC, tag ASC
+LIMIT sqlc.arg('row_limit');
@@ -1,6 +1,6 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3)
 RETURNING *;
 
 -- name: DeleteAllUsers :exec
@@ -17,7 +17,7 @@ SET
     updated_at = NOW()
 WHERE
     id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red;
+RETURNING *;
 
 -- name: UpgradeUser :exec
 UPDATE users
@@ -28,4 +28,71 @@ WHERE
     id = $1;
 
 -- name: GetUserByID :one
-SELECT * FROM users WHERE id = $1;
\ No newline at end of file
+SELECT * FROM users WHERE id = $1;
+
+-- name: SetUserHandle :one
+UPDATE users
+SET
+    handle = $2,
+    updated_at = NOW()
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: GetUsersByHandles :many
+SELECT * FROM users
+WHERE lower(handle) = ANY(sqlc.arg('handles')::text[]);
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET
+    hashed_password = $2,
+    updated_at = NOW()
+WHERE
+    id = $1;
+
+-- name: MarkEmailVerified :one
+UPDATE users
+SET
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE
+    id = $1 AND email = $2
+RETURNING *;
+
+-- name: SetPendingEmail :one
+UPDATE users
+SET
+    pending_email = $2,
+    updated_at = NOW()
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: ConfirmPendingEmail :one
+UPDATE users
+SET
+    email = pending_email,
+    pending_email = NULL,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE
+    id = $1 AND pending_email = $2
+RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users
+SET
+    role = $2,
+    updated_at = NOW()
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: DowngradeUser :exec
+UPDATE users
+SET
+    is_chirpy_red = FALSE,
+    updated_at = NOW()
+WHERE
+    id = $1;
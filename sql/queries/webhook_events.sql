@@ -0,0 +1,8 @@
+-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (id, event, received_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: DeleteWebhookEventsBefore :exec
+DELETE FROM webhook_events
+WHERE received_at < $1;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- root_id has no foreign key so it keeps pointing at the chirp that started
+-- a thread after that chirp is deleted, and the replies still form one thread.
+ALTER TABLE chirps
+ADD COLUMN parent_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN root_id UUID;
+
+CREATE INDEX chirps_root_id_created_at_idx ON chirps (root_id, created_at);
+CREATE INDEX chirps_parent_id_idx ON chirps (parent_id);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN root_id,
+DROP COLUMN parent_id;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN ref_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN ref_kind TEXT CHECK (ref_kind IN ('rechirp', 'quote'));
+
+-- A user can only rechirp a given chirp once. Once the original is deleted
+-- ref_chirp_id becomes NULL and the row no longer takes part in the index.
+CREATE UNIQUE INDEX chirps_user_id_rechirp_idx ON chirps (user_id, ref_chirp_id)
+WHERE ref_kind = 'rechirp';
+
+-- +goose Down
+DROP INDEX chirps_user_id_rechirp_idx;
+ALTER TABLE chirps
+DROP COLUMN ref_kind,
+DROP COLUMN ref_chirp_id;
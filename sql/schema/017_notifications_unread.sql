@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE INDEX notifications_user_id_unread_idx ON notifications (user_id)
+WHERE read_at IS NULL;
+
+-- +goose Down
+DROP INDEX notifications_user_id_unread_idx;
@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    root_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- Every chirp insert and delete is logged so stream clients can resume from
+-- a Last-Event-ID, and announced on the chirp_events channel so every server
+-- instance can push it to its own clients.
+-- +goose StatementBegin
+CREATE FUNCTION log_chirp_event() RETURNS trigger AS $$
+DECLARE
+    ev chirp_events;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO chirp_events (kind, chirp_id, user_id, root_id)
+        VALUES ('created', NEW.id, NEW.user_id, NEW.root_id)
+        RETURNING * INTO ev;
+    ELSE
+        INSERT INTO chirp_events (kind, chirp_id, user_id, root_id)
+        VALUES ('deleted', OLD.id, OLD.user_id, OLD.root_id)
+        RETURNING * INTO ev;
+    END IF;
+    PERFORM pg_notify('chirp_events', json_build_object(
+        'id', ev.id,
+        'kind', ev.kind,
+        'chirp_id', ev.chirp_id,
+        'user_id', ev.user_id,
+        'root_id', ev.root_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_log_event
+AFTER INSERT OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION log_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirps_log_event ON chirps;
+DROP FUNCTION log_chirp_event();
+DROP TABLE chirp_events;
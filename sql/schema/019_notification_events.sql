@@ -0,0 +1,22 @@
+-- +goose Up
+-- New notifications are announced on the notification_events channel so a
+-- live connection on any server instance can push them to their recipient.
+-- +goose StatementBegin
+CREATE FUNCTION announce_notification() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('notification_events', json_build_object(
+        'id', NEW.id,
+        'user_id', NEW.user_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER notifications_announce
+AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION announce_notification();
+
+-- +goose Down
+DROP TRIGGER notifications_announce ON notifications;
+DROP FUNCTION announce_notification();
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Every refresh token belongs to the family started at login; rotating a
+-- token adds the next one to the same family so reuse of an old token can
+-- revoke everything descended from it.
+ALTER TABLE refresh_tokens ADD COLUMN family_id UUID;
+UPDATE refresh_tokens SET family_id = gen_random_uuid();
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- Why a token stopped being valid: 'rotated' when it was exchanged for a new
+-- one, 'revoked' when it was logged out or revoked. Only a rotated token
+-- coming back means it was stolen. Tokens were never rotated before this, so
+-- the ones already revoked were logged out.
+ALTER TABLE refresh_tokens
+ADD COLUMN revoked_reason TEXT CHECK (revoked_reason IN ('rotated', 'revoked'));
+UPDATE refresh_tokens SET revoked_reason = 'revoked' WHERE revoked_at IS NOT NULL;
+
+CREATE TABLE security_events (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX security_events_user_id_idx ON security_events (user_id, created_at);
+
+-- +goose Down
+DROP TABLE security_events;
+ALTER TABLE refresh_tokens
+DROP COLUMN revoked_reason,
+DROP COLUMN family_id;
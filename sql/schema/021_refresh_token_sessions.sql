@@ -0,0 +1,17 @@
+-- +goose Up
+-- A session is a refresh token family; these describe the device behind it
+-- and are refreshed every time the family is rotated.
+ALTER TABLE refresh_tokens
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX refresh_tokens_user_id_live_idx ON refresh_tokens (user_id)
+WHERE revoked_at IS NULL;
+
+-- +goose Down
+DROP INDEX refresh_tokens_user_id_live_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN user_agent,
+    DROP COLUMN ip_address,
+    DROP COLUMN last_used_at;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- pending_email holds a requested email change until the new address is
+-- verified. Accounts that predate verification are treated as verified.
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN pending_email VARCHAR(255);
+UPDATE users SET email_verified_at = created_at;
+
+-- email is the address the token was sent to, which is the one it verifies
+CREATE TABLE email_verifications (
+    token_hash TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX email_verifications_user_id_idx ON email_verifications (user_id);
+
+-- +goose Down
+DROP TABLE email_verifications;
+ALTER TABLE users
+    DROP COLUMN email_verified_at,
+    DROP COLUMN pending_email;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Failed logins are counted per account (the normalized email, whether or
+-- not it belongs to a user) and per client IP. locked_until is set once the
+-- failures pass the free allowance; logins are refused until then. Other
+-- limits, such as on emails sent, keep their counts here under their own
+-- scopes.
+CREATE TABLE login_throttles (
+    scope TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,
+    PRIMARY KEY (scope, subject)
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Third-party apps registered by a user. Public clients (browser and mobile
+-- apps, which cannot keep a secret) have no secret and rely on PKCE alone.
+CREATE TABLE oauth_clients (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    secret_hash TEXT,
+    redirect_uris TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX oauth_clients_owner_id_idx ON oauth_clients (owner_id);
+
+-- Single-use authorization codes. family_id is the refresh token family the
+-- code is redeemed into, so a replayed code can revoke what it was traded for.
+CREATE TABLE oauth_authorization_codes (
+    code_hash TEXT PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    -- Whether the authorization request named redirect_uri. Only then does
+    -- the token request have to repeat it (RFC 6749 section 4.1.3).
+    redirect_uri_given BOOLEAN NOT NULL,
+    scopes TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    family_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- OAuth refresh tokens live alongside the ones from logging in, with the
+-- client they were issued to and the scopes they grant.
+ALTER TABLE refresh_tokens
+    ADD COLUMN client_id UUID REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    ADD COLUMN scopes TEXT[];
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+    DROP COLUMN client_id,
+    DROP COLUMN scopes;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
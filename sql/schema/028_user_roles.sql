@@ -0,0 +1,10 @@
+-- +goose Up
+-- Moderators may remove other people's chirps; admins may also use the
+-- /admin endpoints and change roles. The first admin is the account whose
+-- verified email matches ADMIN_EMAIL.
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN role;
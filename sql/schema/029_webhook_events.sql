@@ -0,0 +1,11 @@
+-- +goose Up
+-- Every Polka webhook that has been processed, so a retried or replayed
+-- delivery is acknowledged without being applied twice.
+CREATE TABLE webhook_events (
+    id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE webhook_events;
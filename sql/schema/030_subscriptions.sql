@@ -0,0 +1,28 @@
+-- +goose Up
+-- A user's Chirpy Red subscription as last reported by Polka. users.is_chirpy_red
+-- stays as the flag the rest of the app checks, and follows the status:
+-- active and past_due subscriptions keep Red until current_period_end.
+-- period_confirmed says whether that end came from Polka; a guessed one never
+-- lapses on its own, and Red lasts until Polka reports otherwise.
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_end TIMESTAMP NOT NULL,
+    period_confirmed BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX subscriptions_period_end_idx ON subscriptions (current_period_end)
+WHERE status IN ('active', 'past_due') AND period_confirmed;
+
+-- Upgrades from before subscriptions were tracked get a month as a
+-- placeholder, which Polka's next event for them replaces
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, period_confirmed)
+SELECT id, 'chirpy_red', 'active', NOW() + INTERVAL '1 month', FALSE
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
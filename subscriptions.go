@@ -0,0 +1,174 @@
+package main
+
+import (
+	"chirpy-project/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Polka events about a user's Chirpy Red subscription.
+const (
+	polkaEventUpgraded      = "user.upgraded"
+	polkaEventRenewed       = "user.renewed"
+	polkaEventPaymentFailed = "user.payment_failed"
+	polkaEventDowngraded    = "user.downgraded"
+)
+
+// Subscription statuses stored in subscriptions.status. Active and past_due
+// subscriptions carry Chirpy Red until their period ends.
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+)
+
+const (
+	defaultSubscriptionPlan = "chirpy_red"
+	// How often lapsed subscriptions are looked for
+	subscriptionExpiryInterval = time.Minute
+)
+
+type polkaEventData struct {
+	UserID uuid.UUID `json:"user_id"`
+	Plan   string    `json:"plan"`
+	// When the paid period ends. Without it the period is guessed as one
+	// month, and Red doesn't lapse at the end of a guess.
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+}
+
+func isSubscriptionEvent(event string) bool {
+	switch event {
+	case polkaEventUpgraded, polkaEventRenewed, polkaEventPaymentFailed, polkaEventDowngraded:
+		return true
+	}
+	return false
+}
+
+// applySubscriptionEvent brings a user's subscription, and with it their
+// Chirpy Red flag, up to date with a Polka event. An unknown user is
+// sql.ErrNoRows.
+func applySubscriptionEvent(ctx context.Context, q *database.Queries, event string, data polkaEventData, now time.Time) error {
+	if _, err := q.GetUserByID(ctx, data.UserID); err != nil {
+		return err
+	}
+	current, err := q.GetSubscription(ctx, data.UserID)
+	hasCurrent := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	live := hasCurrent && subscriptionStatus(current, now) != subscriptionExpired &&
+		current.Status != subscriptionCanceled
+	// An unconfirmed period is only a guess, so it is no base to build on
+	paidUntil := live && current.PeriodConfirmed
+
+	switch event {
+	case polkaEventUpgraded, polkaEventRenewed:
+		plan := data.Plan
+		if plan == "" && hasCurrent {
+			plan = current.Plan
+		}
+		if plan == "" {
+			plan = defaultSubscriptionPlan
+		}
+
+		// Only an end Polka sent, or one it sent before and this keeps, is
+		// confirmed; anything else is a guess
+		var periodEnd time.Time
+		confirmed := false
+		switch {
+		case data.CurrentPeriodEnd != nil:
+			periodEnd = data.CurrentPeriodEnd.UTC()
+			confirmed = true
+		case event == polkaEventRenewed && paidUntil:
+			// A renewal adds a month to what was already paid for
+			periodEnd = current.CurrentPeriodEnd.AddDate(0, 1, 0)
+		case event == polkaEventUpgraded && paidUntil:
+			periodEnd = current.CurrentPeriodEnd
+			confirmed = true
+		default:
+			periodEnd = now.AddDate(0, 1, 0)
+		}
+
+		if _, err := q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           data.UserID,
+			Plan:             plan,
+			Status:           subscriptionActive,
+			CurrentPeriodEnd: periodEnd,
+			PeriodConfirmed:  confirmed,
+		}); err != nil {
+			return err
+		}
+		return q.UpgradeUser(ctx, data.UserID)
+
+	case polkaEventPaymentFailed:
+		// Red is kept for the rest of the paid period; if no renewal comes by
+		// then the subscription expires
+		if !live {
+			return nil
+		}
+		_, err := q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+			UserID: data.UserID,
+			Status: subscriptionPastDue,
+		})
+		return err
+
+	case polkaEventDowngraded:
+		if hasCurrent {
+			if _, err := q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+				UserID: data.UserID,
+				Status: subscriptionCanceled,
+			}); err != nil {
+				return err
+			}
+		}
+		return q.DowngradeUser(ctx, data.UserID)
+	}
+
+	return fmt.Errorf("unknown subscription event %q", event)
+}
+
+// subscriptionStatus is sub's status as of now. A lapsed subscription the
+// expiry loop hasn't got to yet already counts as expired; one whose period
+// Polka hasn't confirmed never lapses.
+func subscriptionStatus(sub database.Subscription, now time.Time) string {
+	if (sub.Status == subscriptionActive || sub.Status == subscriptionPastDue) &&
+		sub.PeriodConfirmed && !sub.CurrentPeriodEnd.After(now) {
+		return subscriptionExpired
+	}
+	return sub.Status
+}
+
+// expireSubscriptions takes Chirpy Red away from users whose subscription
+// period has ended without a renewal, until ctx is done. It also forgets
+// webhook events past webhookEventRetention.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	ticker := time.NewTicker(subscriptionExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		userIDs, err := cfg.dbQueries.ExpireSubscriptions(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("Error expiring subscriptions: %v\n", err)
+		}
+		for _, userID := range userIDs {
+			fmt.Printf("Chirpy Red subscription expired for user %s\n", userID)
+		}
+
+		err = cfg.dbQueries.DeleteWebhookEventsBefore(ctx, time.Now().UTC().Add(-webhookEventRetention))
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("Error pruning webhook events: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}